@@ -3,15 +3,15 @@ package main
 import (
 	"log"
 	"os"
-	"strings"
 
 	"github.com/jessevdk/go-flags"
-
-	"github.com/marmotherder/habitable/common"
 )
 
-func parseArgs() {
-	_, err := flags.ParseArgs(&opts, os.Args)
+func parseArgs() string {
+	parser := flags.NewParser(&opts, flags.Default)
+	parser.SubcommandsOptional = true
+
+	_, err := parser.ParseArgs(os.Args[1:])
 	if err != nil {
 		usedHelp := func() bool {
 			for _, arg := range os.Args {
@@ -27,9 +27,8 @@ func parseArgs() {
 		log.Fatalln(err.Error())
 	}
 
-	common.Variables = make(common.HabitableVariables)
-	for _, env := range os.Environ() {
-		kv := strings.Split(env, "=")
-		common.Variables[kv[0]] = kv[1]
+	if parser.Active != nil {
+		return parser.Active.Name
 	}
+	return ""
 }
@@ -1,6 +1,7 @@
 package command
 
 import (
+	"bufio"
 	"io"
 	"os/exec"
 	"strings"
@@ -10,9 +11,14 @@ import (
 )
 
 func RunCommand(directory string, command string, args ...string) (string, string, error) {
+	return RunCommandWithEnv(directory, nil, command, args...)
+}
+
+func RunCommandWithEnv(directory string, env []string, command string, args ...string) (string, string, error) {
 	common.AppLogger.Trace("running '%s %s' on host at %s", command, args, directory)
 	cmd := exec.Command(command, args...)
 	cmd.Dir = directory
+	cmd.Env = env
 
 	stdOut, err := cmd.StdoutPipe()
 	if err != nil {
@@ -25,7 +31,7 @@ func RunCommand(directory string, command string, args ...string) (string, strin
 		return "", "", err
 	}
 	if err := cmd.Start(); err != nil {
-		common.AppLogger.Error("npm install failed to start")
+		common.AppLogger.Error("failed to start '%s %s' command", command, args)
 		return "", "", err
 	}
 
@@ -36,12 +42,12 @@ func RunCommand(directory string, command string, args ...string) (string, strin
 	wg.Add(2)
 	processStream := func(stream io.ReadCloser, sb *strings.Builder) {
 		defer wg.Done()
-		buf := make([]byte, 80)
+		reader := bufio.NewReader(stream)
 		for {
-			n, err := stream.Read(buf)
-			if n > 0 {
-				sb.WriteString(string(buf[0:n]))
-				common.AppLogger.Debug(string(buf[0:n]))
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				sb.WriteString(line)
+				common.AppLogger.Debug(strings.TrimRight(line, "\r\n"))
 			}
 			if err != nil {
 				break
@@ -55,5 +61,5 @@ func RunCommand(directory string, command string, args ...string) (string, strin
 	wg.Wait()
 	err = cmd.Wait()
 
-	return stdOutSb.String(), stdErrSb.String(), err
+	return common.Redact(stdOutSb.String()), common.Redact(stdErrSb.String()), err
 }
@@ -7,8 +7,10 @@ import (
 var AppLogger logger.Logger
 
 const (
-	SetupError       = 1
-	ScriptSetupError = 2
+	SetupError          = 1
+	ScriptSetupError    = 2
+	StepDefinitionError = 3
+	Interrupted         = 130
 )
 
 func TempDir() string {
@@ -26,20 +28,3 @@ func TempPluginsDir() string {
 func TempScriptsDir() string {
 	return TempDir() + "/" + "scripts"
 }
-
-var Variables HabitableVariables
-
-type HabitableVariables map[string]string
-
-func (v HabitableVariables) Get(key string) string {
-	AppLogger.Trace("script looking up variable with key %s", key)
-	if val, ok := v[key]; ok {
-		return val
-	}
-	return ""
-}
-
-func (v HabitableVariables) Set(key, value string) {
-	AppLogger.Trace("script setting variable with key %s", key)
-	v[key] = value
-}
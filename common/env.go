@@ -0,0 +1,56 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func ParseKeyValue(line string) (string, string, error) {
+	line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "export "))
+	kv := strings.SplitN(line, "=", 2)
+	if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+		return "", "", fmt.Errorf("expected KEY=VALUE but got %s", line)
+	}
+
+	key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+	switch {
+	case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid quoted value for %s: %s", key, err.Error())
+		}
+		value = unquoted
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		value = value[1 : len(value)-1]
+	default:
+		if idx := strings.Index(value, " #"); idx >= 0 {
+			value = strings.TrimSpace(value[:idx])
+		}
+	}
+
+	return key, value, nil
+}
+
+func ParseEnv(reader io.Reader) (map[string]string, error) {
+	values := map[string]string{}
+
+	scanner := bufio.NewScanner(reader)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, err := ParseKeyValue(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err.Error())
+		}
+		values[key] = value
+	}
+
+	return values, scanner.Err()
+}
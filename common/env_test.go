@@ -0,0 +1,64 @@
+package common
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseKeyValue(t *testing.T) {
+	tests := []struct {
+		line    string
+		key     string
+		value   string
+		invalid bool
+	}{
+		{"KEY=value", "KEY", "value", false},
+		{"  KEY = value  ", "KEY", "value", false},
+		{"export KEY=value", "KEY", "value", false},
+		{"KEY=", "KEY", "", false},
+		{"KEY=a=b", "KEY", "a=b", false},
+		{`KEY="quoted value"`, "KEY", "quoted value", false},
+		{`KEY="line\nbreak"`, "KEY", "line\nbreak", false},
+		{"KEY='single $quoted'", "KEY", "single $quoted", false},
+		{"KEY=value # comment", "KEY", "value", false},
+		{"KEY=value#not-comment", "KEY", "value#not-comment", false},
+		{`KEY="value # kept"`, "KEY", "value # kept", false},
+		{"no separator", "", "", true},
+		{"=value", "", "", true},
+		{`KEY="unterminated\"`, "", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			key, value, err := ParseKeyValue(test.line)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("ParseKeyValue(%q) should return an error", test.line)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseKeyValue(%q) returned error: %s", test.line, err.Error())
+			}
+			if key != test.key || value != test.value {
+				t.Errorf("ParseKeyValue(%q) = %q, %q, want %q, %q", test.line, key, value, test.key, test.value)
+			}
+		})
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	values, err := ParseEnv(strings.NewReader("# comment\n\nFIRST=1\nexport SECOND=\"two\"\nFIRST=override\n"))
+	if err != nil {
+		t.Fatalf("ParseEnv returned error: %s", err.Error())
+	}
+	expected := map[string]string{"FIRST": "override", "SECOND": "two"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("ParseEnv = %v, want %v", values, expected)
+	}
+
+	if _, err := ParseEnv(strings.NewReader("GOOD=1\nbad line\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("ParseEnv should report the malformed line number, got %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type VariableScope int
+
+const (
+	EnvironmentScope VariableScope = iota
+	SuiteScope
+	FeatureScope
+	ScenarioScope
+)
+
+var variableScopeNames = []string{"environment", "suite", "feature", "scenario"}
+
+func (s VariableScope) String() string {
+	return variableScopeNames[s]
+}
+
+func ParseVariableScope(name string) (VariableScope, error) {
+	for idx, scopeName := range variableScopeNames {
+		if strings.EqualFold(name, scopeName) {
+			return VariableScope(idx), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown variable scope %s, expected one of %s", name, strings.Join(variableScopeNames, ", "))
+}
+
+var Variables *HabitableVariables
+
+const RedactedValue = "***"
+
+type HabitableVariables struct {
+	mu             sync.RWMutex
+	layers         []map[string]string
+	features       map[string]map[string]string
+	defaultScope   VariableScope
+	secrets        map[string]bool
+	secretPatterns []string
+}
+
+func NewHabitableVariables(environment map[string]string) *HabitableVariables {
+	v := &HabitableVariables{
+		layers:       make([]map[string]string, len(variableScopeNames)),
+		features:     map[string]map[string]string{},
+		defaultScope: ScenarioScope,
+		secrets:      map[string]bool{},
+	}
+	for idx := range v.layers {
+		v.layers[idx] = map[string]string{}
+	}
+	for key, value := range environment {
+		v.layers[EnvironmentScope][key] = value
+	}
+	return v
+}
+
+func (v *HabitableVariables) lookup(key string) (string, VariableScope, bool) {
+	for scope := ScenarioScope; scope >= EnvironmentScope; scope-- {
+		if val, ok := v.layers[scope][key]; ok {
+			return val, scope, true
+		}
+	}
+	return "", 0, false
+}
+
+func (v *HabitableVariables) Get(key string) string {
+	AppLogger.Trace("script looking up variable with key %s", key)
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	val, _, _ := v.lookup(key)
+	return val
+}
+
+func (v *HabitableVariables) Set(key, value string) {
+	v.mu.RLock()
+	scope := v.defaultScope
+	v.mu.RUnlock()
+	v.SetScoped(scope, key, value)
+}
+
+// SetDefaultScope changes the scope used by Set, scripts are registered
+// with suite scope so their top level values outlive each scenario
+func (v *HabitableVariables) SetDefaultScope(scope VariableScope) VariableScope {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	previous := v.defaultScope
+	v.defaultScope = scope
+	return previous
+}
+
+func (v *HabitableVariables) SetScoped(scope VariableScope, key, value string) {
+	AppLogger.Trace("script setting variable with key %s in %s scope", key, scope)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.layers[scope][key] = value
+}
+
+func (v *HabitableVariables) SetIn(scope, key, value string) error {
+	variableScope, err := ParseVariableScope(scope)
+	if err != nil {
+		return err
+	}
+	v.SetScoped(variableScope, key, value)
+	return nil
+}
+
+func (v *HabitableVariables) Promote(key, scope string) error {
+	variableScope, err := ParseVariableScope(scope)
+	if err != nil {
+		return err
+	}
+	AppLogger.Trace("promoting variable with key %s to %s scope", key, variableScope)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	val, current, ok := v.lookup(key)
+	if !ok {
+		return fmt.Errorf("cannot promote variable %s as it is not set", key)
+	}
+	if current < variableScope {
+		return fmt.Errorf("cannot promote variable %s from %s scope to narrower %s scope", key, current, variableScope)
+	}
+
+	for s := variableScope + 1; s <= ScenarioScope; s++ {
+		delete(v.layers[s], key)
+	}
+	v.layers[variableScope][key] = val
+	return nil
+}
+
+func (v *HabitableVariables) Reset(scope VariableScope) {
+	AppLogger.Trace("resetting variables from %s scope", scope)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for s := scope; s <= ScenarioScope; s++ {
+		if s != EnvironmentScope {
+			v.layers[s] = map[string]string{}
+		}
+	}
+	if scope <= FeatureScope {
+		v.features = map[string]map[string]string{}
+	}
+}
+
+// StartScenario clears the scenario scope and switches the feature scope to
+// the layer kept for the given feature, so scenarios from other features
+// running in between do not reset it
+func (v *HabitableVariables) StartScenario(feature string) {
+	AppLogger.Trace("starting scenario variables for feature %s", feature)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	layer, ok := v.features[feature]
+	if !ok {
+		layer = map[string]string{}
+		v.features[feature] = layer
+	}
+	v.layers[FeatureScope] = layer
+	v.layers[ScenarioScope] = map[string]string{}
+}
+
+func (v *HabitableVariables) Map() map[string]string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	values := map[string]string{}
+	for _, layer := range v.layers {
+		for key, value := range layer {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+func (v *HabitableVariables) Keys() []string {
+	keys := []string{}
+	for key := range v.Map() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (v *HabitableVariables) Scope(key string) (VariableScope, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	_, scope, ok := v.lookup(key)
+	return scope, ok
+}
+
+func (v *HabitableVariables) SetSecret(key, value string) {
+	v.MarkSecret(key)
+	v.Set(key, value)
+}
+
+func (v *HabitableVariables) MarkSecret(key string) {
+	AppLogger.Trace("marking variable with key %s as secret", key)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.secrets[key] = true
+}
+
+func (v *HabitableVariables) AddSecretPattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid secret pattern %s: %s", pattern, err.Error())
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.secretPatterns = append(v.secretPatterns, pattern)
+	return nil
+}
+
+func (v *HabitableVariables) IsSecret(key string) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.isSecret(key)
+}
+
+func (v *HabitableVariables) isSecret(key string) bool {
+	if v.secrets[key] {
+		return true
+	}
+	for _, pattern := range v.secretPatterns {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *HabitableVariables) Redact(text string) string {
+	v.mu.RLock()
+	values := []string{}
+	for _, layer := range v.layers {
+		for key, value := range layer {
+			if value != "" && v.isSecret(key) {
+				values = append(values, value)
+			}
+		}
+	}
+	v.mu.RUnlock()
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+
+	for _, value := range values {
+		text = strings.ReplaceAll(text, value, RedactedValue)
+	}
+	return text
+}
+
+func Redact(text string) string {
+	if Variables == nil {
+		return text
+	}
+	return Variables.Redact(text)
+}
+
+type redactWriter struct {
+	out io.Writer
+}
+
+func (w redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func RedactWriter(out io.Writer) io.Writer {
+	return redactWriter{out: out}
+}
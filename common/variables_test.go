@@ -0,0 +1,263 @@
+package common
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/marmotherder/habitable/logger"
+)
+
+func TestMain(m *testing.M) {
+	AppLogger = logger.DefaultLogger{}
+	os.Exit(m.Run())
+}
+
+func TestParseVariableScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected VariableScope
+		invalid  bool
+	}{
+		{"environment", EnvironmentScope, false},
+		{"Suite", SuiteScope, false},
+		{"FEATURE", FeatureScope, false},
+		{"scenario", ScenarioScope, false},
+		{"global", 0, true},
+		{"", 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scope, err := ParseVariableScope(test.name)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("ParseVariableScope(%q) should return an error", test.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseVariableScope(%q) returned error: %s", test.name, err.Error())
+			}
+			if scope != test.expected {
+				t.Errorf("ParseVariableScope(%q) = %s, want %s", test.name, scope, test.expected)
+			}
+		})
+	}
+}
+
+func TestVariableScopes(t *testing.T) {
+	variables := NewHabitableVariables(map[string]string{"env": "environment", "shadowed": "environment"})
+	variables.SetScoped(SuiteScope, "suite", "suite")
+	variables.SetScoped(SuiteScope, "shadowed", "suite")
+	variables.StartScenario("a.feature")
+	variables.SetScoped(FeatureScope, "feature", "a")
+	variables.Set("scenario", "scenario")
+	variables.Set("shadowed", "scenario")
+
+	tests := []struct {
+		key   string
+		value string
+		scope VariableScope
+	}{
+		{"env", "environment", EnvironmentScope},
+		{"suite", "suite", SuiteScope},
+		{"feature", "a", FeatureScope},
+		{"scenario", "scenario", ScenarioScope},
+		{"shadowed", "scenario", ScenarioScope},
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			if value := variables.Get(test.key); value != test.value {
+				t.Errorf("Get(%q) = %q, want %q", test.key, value, test.value)
+			}
+			if scope, ok := variables.Scope(test.key); !ok || scope != test.scope {
+				t.Errorf("Scope(%q) = %s, %t, want %s", test.key, scope, ok, test.scope)
+			}
+		})
+	}
+
+	if keys := variables.Keys(); !reflect.DeepEqual(keys, []string{"env", "feature", "scenario", "shadowed", "suite"}) {
+		t.Errorf("Keys() = %q", keys)
+	}
+}
+
+func TestStartScenario(t *testing.T) {
+	variables := NewHabitableVariables(nil)
+
+	variables.StartScenario("a.feature")
+	variables.SetScoped(FeatureScope, "feature", "a")
+	variables.Set("scenario", "first")
+
+	variables.StartScenario("b.feature")
+	if value := variables.Get("feature"); value != "" {
+		t.Errorf("feature variable of a.feature leaked into b.feature as %q", value)
+	}
+	variables.SetScoped(FeatureScope, "feature", "b")
+
+	variables.StartScenario("a.feature")
+	if value := variables.Get("feature"); value != "a" {
+		t.Errorf("feature variable of a.feature = %q after running b.feature, want %q", value, "a")
+	}
+	if value := variables.Get("scenario"); value != "" {
+		t.Errorf("scenario variable = %q in the next scenario, want it cleared", value)
+	}
+
+	variables.Reset(FeatureScope)
+	variables.StartScenario("b.feature")
+	if value := variables.Get("feature"); value != "" {
+		t.Errorf("feature variable = %q after resetting feature scope, want it cleared", value)
+	}
+}
+
+func TestSetDefaultScope(t *testing.T) {
+	variables := NewHabitableVariables(nil)
+
+	previous := variables.SetDefaultScope(SuiteScope)
+	if previous != ScenarioScope {
+		t.Errorf("default scope = %s, want %s", previous, ScenarioScope)
+	}
+	variables.Set("registered", "value")
+	variables.SetDefaultScope(previous)
+
+	variables.StartScenario("a.feature")
+	if value := variables.Get("registered"); value != "value" {
+		t.Errorf("variable set with suite default scope = %q after starting a scenario, want %q", value, "value")
+	}
+}
+
+func TestReset(t *testing.T) {
+	tests := []struct {
+		scope     VariableScope
+		remaining []string
+	}{
+		{EnvironmentScope, []string{"env"}},
+		{SuiteScope, []string{"env"}},
+		{FeatureScope, []string{"env", "suite"}},
+		{ScenarioScope, []string{"env", "feature", "suite"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.scope.String(), func(t *testing.T) {
+			variables := NewHabitableVariables(map[string]string{"env": "value"})
+			variables.StartScenario("a.feature")
+			variables.SetScoped(SuiteScope, "suite", "value")
+			variables.SetScoped(FeatureScope, "feature", "value")
+			variables.SetScoped(ScenarioScope, "scenario", "value")
+
+			variables.Reset(test.scope)
+			if keys := variables.Keys(); !reflect.DeepEqual(keys, test.remaining) {
+				t.Errorf("Reset(%s) left %q, want %q", test.scope, keys, test.remaining)
+			}
+		})
+	}
+}
+
+func TestPromote(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    VariableScope
+		to      string
+		invalid bool
+	}{
+		{"scenario to suite", ScenarioScope, "suite", false},
+		{"scenario to feature", ScenarioScope, "feature", false},
+		{"feature to suite", FeatureScope, "suite", false},
+		{"same scope", SuiteScope, "suite", false},
+		{"suite to scenario", SuiteScope, "scenario", true},
+		{"unknown scope", ScenarioScope, "global", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			variables := NewHabitableVariables(nil)
+			variables.StartScenario("a.feature")
+			variables.SetScoped(test.from, "key", "value")
+
+			err := variables.Promote("key", test.to)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("Promote to %s should return an error", test.to)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Promote returned error: %s", err.Error())
+			}
+
+			expected, _ := ParseVariableScope(test.to)
+			if scope, _ := variables.Scope("key"); scope != expected {
+				t.Errorf("variable is in %s scope after promotion, want %s", scope, expected)
+			}
+			variables.Reset(expected + 1)
+			if value := variables.Get("key"); value != "value" {
+				t.Errorf("promoted variable = %q after resetting narrower scopes, want %q", value, "value")
+			}
+		})
+	}
+
+	if err := NewHabitableVariables(nil).Promote("missing", "suite"); err == nil {
+		t.Error("promoting a variable that is not set should return an error")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	variables := NewHabitableVariables(map[string]string{"PLAIN": "visible"})
+	variables.SetSecret("TOKEN", "hunter2")
+	variables.SetSecret("LONG_TOKEN", "hunter2hunter2")
+	variables.SetSecret("EMPTY", "")
+	variables.SetScoped(SuiteScope, "API_KEY", "abc123")
+	if err := variables.AddSecretPattern("*_KEY"); err != nil {
+		t.Fatalf("AddSecretPattern returned error: %s", err.Error())
+	}
+
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"token is hunter2", "token is ***"},
+		{"long token is hunter2hunter2", "long token is ***"},
+		{"key abc123 and hunter2", "key *** and ***"},
+		{"plain is visible", "plain is visible"},
+		{"nothing secret", "nothing secret"},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			if redacted := variables.Redact(test.text); redacted != test.expected {
+				t.Errorf("Redact(%q) = %q, want %q", test.text, redacted, test.expected)
+			}
+		})
+	}
+
+	for key, secret := range map[string]bool{"TOKEN": true, "API_KEY": true, "OTHER_KEY": true, "PLAIN": false, "KEY": false} {
+		if variables.IsSecret(key) != secret {
+			t.Errorf("IsSecret(%q) = %t, want %t", key, !secret, secret)
+		}
+	}
+
+	if err := variables.AddSecretPattern("["); err == nil {
+		t.Error("AddSecretPattern should reject an invalid pattern")
+	}
+}
+
+func TestRedactWriter(t *testing.T) {
+	defer func(variables *HabitableVariables) {
+		Variables = variables
+	}(Variables)
+	Variables = NewHabitableVariables(nil)
+	Variables.SetSecret("TOKEN", "hunter2")
+
+	out := &strings.Builder{}
+	writer := RedactWriter(out)
+	n, err := writer.Write([]byte("token hunter2\n"))
+	if err != nil {
+		t.Fatalf("Write returned error: %s", err.Error())
+	}
+	if n != len("token hunter2\n") {
+		t.Errorf("Write reported %d bytes, want the %d given", n, len("token hunter2\n"))
+	}
+	if out.String() != "token ***\n" {
+		t.Errorf("RedactWriter wrote %q", out.String())
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+type habitableConfig struct {
+	Template string                   `yaml:"template"`
+	Profiles map[string]profileConfig `yaml:"profiles"`
+	Secrets  secretsConfig            `yaml:"secrets"`
+	Plugins  pluginsConfig            `yaml:"plugins"`
+}
+
+type pluginsConfig struct {
+	Mirrors []string `yaml:"mirrors"`
+	Indexes []string `yaml:"indexes"`
+}
+
+type secretsConfig struct {
+	Patterns []string `yaml:"patterns"`
+	Prefixes []string `yaml:"prefixes"`
+	Files    []string `yaml:"files"`
+}
+
+type profileConfig struct {
+	Vars  map[string]interface{} `yaml:"vars"`
+	Files []string               `yaml:"files"`
+}
+
+func loadConfig(path string) (*habitableConfig, error) {
+	config := &habitableConfig{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return config, nil
+		}
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %s", path, err.Error())
+	}
+
+	switch config.Template {
+	case "", mustacheTemplates, goTemplates:
+	default:
+		return nil, fmt.Errorf("unknown template engine %s in config file %s, expected %s or %s", config.Template, path, mustacheTemplates, goTemplates)
+	}
+
+	return config, nil
+}
+
+func (c *habitableConfig) profile(name string) (profileConfig, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return profileConfig{}, fmt.Errorf("profile %s is not defined in config file %s", name, opts.Config)
+	}
+	return profile, nil
+}
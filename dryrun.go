@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/features"
+	"github.com/marmotherder/habitable/scripting"
+)
+
+// dryRunWildcard stands in for variables that are not set until runtime
+const dryRunWildcard = "\x1f"
+
+func dryRun(out io.Writer, paths []string) (bool, error) {
+	out = common.RedactWriter(out)
+	definitions, err := scripting.CollectSteps()
+	if err != nil {
+		return false, err
+	}
+	steps, err := features.LoadSteps(paths...)
+	if err != nil {
+		return false, err
+	}
+
+	undefined, ambiguous, pending, unresolved := 0, 0, 0, 0
+	for _, step := range steps {
+		variables := common.Variables.Map()
+		texts := []*string{&step.Text}
+		for _, text := range append(texts, stepArgumentValues(step.Argument)...) {
+			for _, name := range unresolvedVariables(*text, variables) {
+				unresolved++
+				fmt.Fprintf(out, "warning: unresolved variable {{%s}} at %s\n", name, step.Location())
+			}
+		}
+
+		// variables set by earlier steps only exist at runtime, so their
+		// placeholders match any value instead of rendering as empty strings
+		for _, name := range unresolvedVariables(step.Text, variables) {
+			variables[name] = dryRunWildcard
+		}
+		text, parts := step.Text, []string{step.Text}
+		if rendered, err := renderTemplate(step.Text, variables, false); err == nil {
+			parts = strings.Split(rendered, dryRunWildcard)
+			if len(parts) == 1 {
+				text = rendered
+			}
+		}
+
+		matches := scripting.MatchingStepsWithWildcards(definitions, parts)
+		switch {
+		case len(matches) == 0:
+			undefined++
+			fmt.Fprintf(out, "undefined: \"%s\" at %s\n", text, step.Location())
+		case len(matches) > 1:
+			ambiguous++
+			fmt.Fprintf(out, "ambiguous: \"%s\" at %s matches:\n", text, step.Location())
+			for _, match := range matches {
+				fmt.Fprintf(out, "  %s (%s)\n", match.Pattern, stepLocation(match))
+			}
+		case matches[0].Pending:
+			pending++
+			fmt.Fprintf(out, "pending: \"%s\" at %s (%s)\n", text, step.Location(), stepLocation(matches[0]))
+		}
+	}
+
+	summary := []string{
+		fmt.Sprintf("%d undefined", undefined),
+		fmt.Sprintf("%d ambiguous", ambiguous),
+		fmt.Sprintf("%d pending", pending),
+		fmt.Sprintf("%d unresolved variables", unresolved),
+	}
+	fmt.Fprintf(out, "%d steps checked: %s\n", len(steps), strings.Join(summary, ", "))
+
+	return undefined+ambiguous+pending == 0, nil
+}
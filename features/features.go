@@ -0,0 +1,154 @@
+package features
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cucumber/gherkin-go/v19"
+	"github.com/cucumber/messages-go/v16"
+
+	"github.com/marmotherder/habitable/common"
+)
+
+type Step struct {
+	Uri      string
+	Line     int64
+	Scenario string
+	Text     string
+	Argument *messages.PickleStepArgument
+}
+
+func (s Step) Location() string {
+	return fmt.Sprintf("%s:%d", s.Uri, s.Line)
+}
+
+var pathLineRe = regexp.MustCompile(`:([\d]+)$`)
+
+func Paths(paths []string) []string {
+	if len(paths) == 0 {
+		return []string{"features"}
+	}
+	return paths
+}
+
+func LoadSteps(paths ...string) ([]Step, error) {
+	steps := []Step{}
+	seen := map[string]bool{}
+	for _, path := range Paths(paths) {
+		line := int64(-1)
+		if m := pathLineRe.FindStringSubmatch(path); len(m) > 0 {
+			if i, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+				line = i
+				path = path[:strings.LastIndexByte(path, ':')]
+			}
+		}
+
+		files, err := featureFiles(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			if seen[file] && line == -1 {
+				continue
+			}
+			seen[file] = true
+
+			fileSteps, err := loadFile(file, line)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, fileSteps...)
+		}
+	}
+
+	return steps, nil
+}
+
+func featureFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf(`feature path "%s" is not available`, path)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	files := []string{}
+	return files, filepath.Walk(path, func(p string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !f.IsDir() && strings.HasSuffix(p, ".feature") {
+			files = append(files, p)
+		}
+		return nil
+	})
+}
+
+func loadFile(path string, line int64) ([]Step, error) {
+	common.AppLogger.Trace("parsing feature file %s", path)
+	reader, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	newId := (&messages.Incrementing{}).NewId
+	document, err := gherkin.ParseGherkinDocument(reader, newId)
+	if err != nil {
+		return nil, fmt.Errorf("%s - %v", path, err)
+	}
+	if document.Feature == nil {
+		return []Step{}, nil
+	}
+
+	lines := map[string]int64{}
+	addSteps := func(steps []*messages.Step) {
+		for _, step := range steps {
+			lines[step.Id] = step.Location.Line
+		}
+	}
+	for _, child := range document.Feature.Children {
+		if child.Background != nil {
+			addSteps(child.Background.Steps)
+		}
+		if child.Scenario != nil {
+			lines[child.Scenario.Id] = child.Scenario.Location.Line
+			addSteps(child.Scenario.Steps)
+		}
+		if child.Rule != nil {
+			for _, ruleChild := range child.Rule.Children {
+				if ruleChild.Background != nil {
+					addSteps(ruleChild.Background.Steps)
+				}
+				if ruleChild.Scenario != nil {
+					lines[ruleChild.Scenario.Id] = ruleChild.Scenario.Location.Line
+					addSteps(ruleChild.Scenario.Steps)
+				}
+			}
+		}
+	}
+
+	steps := []Step{}
+	for _, pickle := range gherkin.Pickles(*document, path, newId) {
+		if line != -1 && lines[pickle.AstNodeIds[0]] != line {
+			continue
+		}
+		for _, pickleStep := range pickle.Steps {
+			steps = append(steps, Step{
+				Uri:      path,
+				Line:     lines[pickleStep.AstNodeIds[0]],
+				Scenario: pickle.Name,
+				Text:     pickleStep.Text,
+				Argument: pickleStep.Argument,
+			})
+		}
+	}
+
+	return steps, nil
+}
@@ -1,26 +1,31 @@
 module github.com/marmotherder/habitable
 
-go 1.17
+go 1.18
 
 require (
+	github.com/cucumber/gherkin-go/v19 v19.0.3
 	github.com/cucumber/godog v0.12.4
+	github.com/cucumber/messages-go/v16 v16.0.1
 	github.com/dop251/goja v0.0.0-20220110113543-261677941f3c
 	github.com/dop251/goja_nodejs v0.0.0-20211022123610-8dd9abb0616d
+	github.com/gofrs/uuid v4.0.0+incompatible
 	github.com/hoisie/mustache v0.0.0-20160804235033-6375acf62c69
 	github.com/jessevdk/go-flags v1.5.0
+	github.com/tetratelabs/wazero v1.0.0
+	github.com/traefik/yaegi v0.11.3
+	github.com/yuin/gopher-lua v1.1.1
 	golang.org/x/mod v0.5.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/cucumber/gherkin-go/v19 v19.0.3 // indirect
-	github.com/cucumber/messages-go/v16 v16.0.1 // indirect
 	github.com/dlclark/regexp2 v1.4.1-0.20201116162257-a2a8dda75c91 // indirect
 	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
-	github.com/gofrs/uuid v4.0.0+incompatible // indirect
 	github.com/hashicorp/go-immutable-radix v1.3.0 // indirect
 	github.com/hashicorp/go-memdb v1.3.0 // indirect
 	github.com/hashicorp/golang-lru v0.5.4 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	golang.org/x/sys v0.0.0-20210320140829-1e4c9ba3b0c4 // indirect
 	golang.org/x/text v0.3.6 // indirect
+	golang.org/x/xerrors v0.0.0-20191011141410-1b5146add898 // indirect
 )
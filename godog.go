@@ -2,25 +2,76 @@ package main
 
 import (
 	"context"
+	"errors"
+	"io"
+	"strings"
 
 	"github.com/cucumber/godog"
-	"github.com/hoisie/mustache"
 	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/plugins"
 	"github.com/marmotherder/habitable/scripting"
 )
 
-func InitializeTestSuite(ctx *godog.TestSuiteContext) {}
+const redactedFormatPrefix = "redacted-"
+
+// redactFormats swaps the requested formatters for ones writing through the
+// redactor, godog reports undefined and skipped steps before the after step
+// hook runs and step errors never pass through it, so the step text alone
+// cannot be relied on to hide secrets
+func redactFormats(format string) string {
+	formats := strings.Split(format, ",")
+	for idx, formatter := range formats {
+		parts := strings.SplitN(formatter, ":", 2)
+		inner := godog.FindFmt(parts[0])
+		if inner == nil {
+			continue
+		}
+		name := redactedFormatPrefix + parts[0]
+		if godog.FindFmt(name) == nil {
+			godog.Format(name, godog.AvailableFormatters()[parts[0]], func(suite string, out io.Writer) godog.Formatter {
+				return inner(suite, common.RedactWriter(out))
+			})
+		}
+		parts[0] = name
+		formats[idx] = strings.Join(parts, ":")
+	}
+	return strings.Join(formats, ",")
+}
+
+func InitializeTestSuite(ctx *godog.TestSuiteContext) {
+	ctx.BeforeSuite(func() {
+		common.Variables.Reset(common.SuiteScope)
+	})
+	ctx.AfterSuite(func() {
+		if !opts.Watch {
+			plugins.ClosePlugins()
+		}
+	})
+
+	common.AppLogger.Info("registering plugin defined suite hooks")
+	plugins.InitializeTestSuite(ctx)
+}
 
 func InitializeScenario(ctx *godog.ScenarioContext) {
 	common.AppLogger.Debug("running godog with context %s", *ctx)
+	ctx.Before(func(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
+		common.Variables.StartScenario(sc.Uri)
+		return ctx, nil
+	})
 	ctx.StepContext().Before(func(ctx context.Context, st *godog.Step) (context.Context, error) {
 		common.AppLogger.Debug("performing feature file substitution")
-		mustacheText, err := mustache.ParseString(st.Text)
-		if err != nil {
-			return nil, err
+		if err := renderStep(st, opts.StrictVars); err != nil {
+			return ctx, err
 		}
-		st.Text = mustacheText.Render(common.Variables)
 		common.AppLogger.Trace(st.Text)
+		return scripting.WithStepArgument(ctx, st.Argument), nil
+	})
+	ctx.StepContext().After(func(ctx context.Context, st *godog.Step, status godog.StepResultStatus, err error) (context.Context, error) {
+		redactStep(st)
+		if errors.Is(err, godog.ErrUndefined) {
+			addUndefinedStep(st.Text)
+		}
+		// godog appends hook errors to the step error, returning err would report it twice
 		return ctx, nil
 	})
 
@@ -2,8 +2,12 @@ package hashes
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"io"
 	"os"
+	"path/filepath"
 
 	"golang.org/x/mod/sumdb/dirhash"
 
@@ -64,6 +68,21 @@ func updateHashesFile(hashes hashData) error {
 }
 
 func CheckDirectoryHashes(directories ...string) (hasChanges bool, err error) {
+	return checkDirectoryHashes(true, directories...)
+}
+
+// DirectoryHashesChanged checks directories without saving their hashes, so a
+// failed build can call it again, SaveDirectoryHashes records a success
+func DirectoryHashesChanged(directories ...string) (hasChanges bool, err error) {
+	return checkDirectoryHashes(false, directories...)
+}
+
+func SaveDirectoryHashes(directories ...string) error {
+	_, err := checkDirectoryHashes(true, directories...)
+	return err
+}
+
+func checkDirectoryHashes(save bool, directories ...string) (hasChanges bool, err error) {
 	hashes, loadErr := loadHashes()
 	if loadErr != nil {
 		err = loadErr
@@ -72,8 +91,8 @@ func CheckDirectoryHashes(directories ...string) (hasChanges bool, err error) {
 
 	common.AppLogger.Debug("checking hashes for %s", directories)
 	for _, directory := range directories {
-		dirhash, hashErr := dirhash.HashDir(directory, "", dirhash.DefaultHash)
-		if err != nil {
+		dirhash, hashErr := HashDirectory(directory)
+		if hashErr != nil {
 			common.AppLogger.Error("failed to hash %s", directory)
 			err = hashErr
 			return
@@ -97,6 +116,9 @@ func CheckDirectoryHashes(directories ...string) (hasChanges bool, err error) {
 		common.AppLogger.Debug("no changes found in hashes, continuing")
 		return
 	}
+	if !save {
+		return
+	}
 
 	common.AppLogger.Debug("changes in hashes found, updating hashes.json")
 	if err = updateHashesFile(hashes); err != nil {
@@ -106,20 +128,68 @@ func CheckDirectoryHashes(directories ...string) (hasChanges bool, err error) {
 	return
 }
 
+func HashDirectory(directory string) (string, error) {
+	files := []string{}
+	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(directory, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return dirhash.Hash1(files, func(name string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(directory, name))
+	})
+}
+
 func CheckStringHash(id, input string) (hasChanges bool, err error) {
+	return checkStringHash(true, id, input)
+}
+
+func StringHashChanged(id, input string) (hasChanges bool, err error) {
+	return checkStringHash(false, id, input)
+}
+
+func SaveStringHash(id, input string) error {
+	_, err := checkStringHash(true, id, input)
+	return err
+}
+
+func checkStringHash(save bool, id, input string) (hasChanges bool, err error) {
 	hashes, loadErr := loadHashes()
-	if err != nil {
+	if loadErr != nil {
 		err = loadErr
 		return
 	}
 
 	h := sha1.New()
 	h.Write([]byte(input))
-	bs := string(h.Sum(nil))
+	sum := h.Sum(nil)
+	bs := hex.EncodeToString(sum)
 	common.AppLogger.Trace("got the following hash for id %s: %s", id, bs)
 
+	migrated := false
 	if existing, ok := hashes.Files[id]; ok {
-		if existing != bs {
+		switch existing {
+		case bs:
+		case legacyStringHash(sum):
+			common.AppLogger.Trace("migrating string id %s to a hex encoded hash", id)
+			migrated = true
+		default:
 			common.AppLogger.Trace("string id %s has been changed", id)
 			hasChanges = true
 		}
@@ -132,6 +202,12 @@ func CheckStringHash(id, input string) (hasChanges bool, err error) {
 
 	if !hasChanges {
 		common.AppLogger.Debug("no changes found in hashes, continuing")
+		if migrated && save {
+			err = updateHashesFile(hashes)
+		}
+		return
+	}
+	if !save {
 		return
 	}
 
@@ -142,3 +218,28 @@ func CheckStringHash(id, input string) (hasChanges bool, err error) {
 
 	return
 }
+
+// legacyStringHash is how string hashes were stored before being hex encoded,
+// the raw digest was written as a string so compare it as it reads back from
+// hashes.json after invalid utf-8 has been replaced
+func legacyStringHash(sum []byte) string {
+	legacy := ""
+	if encoded, err := json.Marshal(string(sum)); err == nil {
+		json.Unmarshal(encoded, &legacy)
+	}
+	return legacy
+}
+
+func FileSha256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,71 @@
+package hashes
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/logger"
+)
+
+func TestStringHashes(t *testing.T) {
+	common.AppLogger = logger.DefaultLogger{}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.MkdirAll(common.TempBuildDir(), 0740); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha1.Sum([]byte("legacy"))
+	legacy, err := json.Marshal(hashData{
+		Files:       map[string]string{"legacy": string(sum[:])},
+		Directories: map[string]string{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(common.TempBuildDir(), "hashes.json"), legacy, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name     string
+		check    func() (bool, error)
+		expected bool
+	}{
+		{"new id", func() (bool, error) { return StringHashChanged("id", "one") }, true},
+		{"new id is not saved by a check", func() (bool, error) { return StringHashChanged("id", "one") }, true},
+		{"save", func() (bool, error) { return false, SaveStringHash("id", "one") }, false},
+		{"saved id", func() (bool, error) { return StringHashChanged("id", "one") }, false},
+		{"changed input", func() (bool, error) { return StringHashChanged("id", "two") }, true},
+		{"legacy hash", func() (bool, error) { return CheckStringHash("legacy", "legacy") }, false},
+		{"legacy hash changed", func() (bool, error) { return StringHashChanged("legacy", "other") }, true},
+	}
+	for _, step := range steps {
+		changed, err := step.check()
+		if err != nil {
+			t.Fatalf("%s returned error: %s", step.name, err.Error())
+		}
+		if changed != step.expected {
+			t.Errorf("%s reported changed %t, want %t", step.name, changed, step.expected)
+		}
+	}
+
+	hashes, err := loadHashes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashes.Files["legacy"] != hex.EncodeToString(sum[:]) {
+		t.Errorf("legacy hash was not migrated to hex, got %q", hashes.Files["legacy"])
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/copy"
+	"github.com/marmotherder/habitable/plugins"
+)
+
+type lockCommand struct{}
+
+func loadLock() error {
+	if opts.Frozen && !copy.Exists(opts.LockFile) {
+		return fmt.Errorf("lock file %s is required when running frozen", opts.LockFile)
+	}
+
+	lock, err := plugins.LoadLock(opts.LockFile)
+	if err != nil {
+		return err
+	}
+	common.AppLogger.Debug("loaded %d plugins from lock file %s", len(lock.Plugins), opts.LockFile)
+
+	plugins.Lock = lock
+	plugins.Frozen = opts.Frozen
+	return nil
+}
@@ -25,7 +25,9 @@ type Logger interface {
 }
 
 type DefaultLogger struct {
-	Level int
+	Level  int
+	Redact func(string) string
+	Exit   func(int)
 }
 
 func (l DefaultLogger) GetLevel() int {
@@ -33,41 +35,44 @@ func (l DefaultLogger) GetLevel() int {
 }
 
 func (l DefaultLogger) Fatal(exitCode int, message interface{}, params ...interface{}) {
-	fmtPrint("FATAL", message, params...)
+	l.print("FATAL", message, params...)
+	if l.Exit != nil {
+		l.Exit(exitCode)
+	}
 	os.Exit(exitCode)
 }
 
 func (l DefaultLogger) Error(message interface{}, params ...interface{}) {
 	if l.Level >= ERROR {
-		fmtPrint("ERROR", message, params...)
+		l.print("ERROR", message, params...)
 	}
 }
 
 func (l DefaultLogger) Warn(message interface{}, params ...interface{}) {
 	if l.Level >= WARN {
-		fmtPrint("WARN", message, params...)
+		l.print("WARN", message, params...)
 	}
 }
 
 func (l DefaultLogger) Info(message interface{}, params ...interface{}) {
 	if l.Level >= INFO {
-		fmtPrint("INFO", message, params...)
+		l.print("INFO", message, params...)
 	}
 }
 
 func (l DefaultLogger) Debug(message interface{}, params ...interface{}) {
 	if l.Level >= DEBUG {
-		fmtPrint("DEBUG", message, params...)
+		l.print("DEBUG", message, params...)
 	}
 }
 
 func (l DefaultLogger) Trace(message interface{}, params ...interface{}) {
 	if l.Level >= TRACE {
-		fmtPrint("TRACE", message, params...)
+		l.print("TRACE", message, params...)
 	}
 }
 
-func fmtPrint(level string, message interface{}, params ...interface{}) {
+func (l DefaultLogger) print(level string, message interface{}, params ...interface{}) {
 	fmtMessage := ""
 	if len(params) > 0 {
 		if messageString, ok := message.(string); ok {
@@ -79,5 +84,9 @@ func fmtPrint(level string, message interface{}, params ...interface{}) {
 		fmtMessage = fmt.Sprint(message)
 	}
 
+	if l.Redact != nil {
+		fmtMessage = l.Redact(fmtMessage)
+	}
+
 	log.Printf(level+": %s\n", fmtMessage)
 }
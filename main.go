@@ -2,9 +2,13 @@ package main
 
 import (
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/marmotherder/habitable/common"
 	"github.com/marmotherder/habitable/logger"
+	"github.com/marmotherder/habitable/plugins"
 	"github.com/marmotherder/habitable/scripting"
 
 	"github.com/cucumber/godog"
@@ -17,13 +21,59 @@ var opts struct {
 	Tests      []string `short:"t" long:"test" description:"Path to a BDD test file to run"`
 	TestName   string   `short:"n" long:"name" description:"Name of the full test suite" default:"habitable"`
 	ScriptDirs []string `short:"s" long:"extensions" description:"Path to custom extensions directory" default:"./_scripts"`
+
+	Watch         bool          `short:"w" long:"watch" description:"Watch features, scripts and plugins and re-run the suite on change"`
+	WatchInterval time.Duration `long:"watch-interval" description:"How often to poll for changes in watch mode" default:"1s"`
+	DryRun        bool          `long:"dry-run" description:"Validate features against the registered steps without executing them"`
+	StrictSteps   bool          `long:"strict-steps" description:"Fail the run when step definitions are duplicated or ambiguous"`
+	Snippets      string        `long:"snippets" description:"Language of the snippets generated for undefined steps" choice:"js" choice:"ts" choice:"none" default:"js"`
+	SnippetsFile  string        `long:"snippets-file" description:"File in the first extensions directory to append undefined step snippets to"`
+	Config        string        `long:"config" description:"Path to the habitable config file" default:"habitable.yaml"`
+	Vars          []string      `long:"vars" description:"Path to an env, json or yaml file of variables, later files take precedence"`
+	Template      string        `long:"template" description:"Template engine used to substitute variables, defaults to the config file or mustache" choice:"mustache" choice:"go"`
+	StrictVars    bool          `long:"strict-vars" description:"Fail steps that reference variables which are not set"`
+	Profile       string        `long:"profile" description:"Name of a profile of variables from the config file to apply"`
+	Secrets       []string      `long:"secrets" description:"Path to an env, json or yaml file of variables to load as secrets"`
+	SecretPattern []string      `long:"secret-pattern" description:"Glob pattern of variable names to treat as secrets and redact from output"`
+	PluginMirror  []string      `long:"plugin-mirror" description:"Directory, file:// or http(s) base URL to fetch plugins from, repeat to add fallbacks tried in order"`
+	PluginIndex   []string      `long:"plugin-index" description:"Path or URL of a JSON plugin index used to resolve version ranges, tried before the index.json at the root of each mirror"`
+	LockFile      string        `long:"lock-file" description:"Path to the plugin lock file" default:"habitable.lock"`
+	Frozen        bool          `long:"frozen" description:"Fail when scripts request plugins that differ from the lock file"`
+
+	Steps stepsCommand `command:"steps" description:"List the step definitions registered by scripts and plugins"`
+	Repl  replCommand  `command:"repl" description:"Interactively match and run steps or evaluate javascript"`
+	Lock  lockCommand  `command:"lock" description:"Resolve the plugins used by scripts and record them in the lock file"`
 }
 
 func main() {
-	parseArgs()
+	command := parseArgs()
 
 	common.AppLogger = logger.DefaultLogger{
-		Level: len(opts.LogLevel),
+		Level:  len(opts.LogLevel),
+		Redact: common.Redact,
+		Exit:   exit,
+	}
+
+	config, err := loadConfig(opts.Config)
+	if err != nil {
+		common.AppLogger.Fatal(common.SetupError, err.Error())
+	}
+
+	if opts.Template == "" {
+		opts.Template = config.Template
+	}
+	if opts.Template == "" {
+		opts.Template = mustacheTemplates
+	}
+
+	variables, err := loadVariables(config)
+	if err != nil {
+		common.AppLogger.Fatal(common.SetupError, err.Error())
+	}
+	common.Variables = common.NewHabitableVariables(variables)
+
+	if err := loadSecrets(config); err != nil {
+		common.AppLogger.Fatal(common.SetupError, err.Error())
 	}
 
 	if opts.Clean {
@@ -40,10 +90,60 @@ func main() {
 		}
 	}
 
+	plugins.Mirrors = append(append([]string{}, opts.PluginMirror...), config.Plugins.Mirrors...)
+	plugins.Indexes = append(append([]string{}, opts.PluginIndex...), config.Plugins.Indexes...)
+	common.AppLogger.Debug("using plugin mirrors %v", plugins.Mirrors)
+
+	if command != "lock" {
+		if err := loadLock(); err != nil {
+			common.AppLogger.Fatal(common.SetupError, err.Error())
+		}
+	}
+
 	common.AppLogger.Info("loading scripts")
 	if err := scripting.LoadScripts(opts.ScriptDirs...); err != nil {
 		common.AppLogger.Fatal(common.ScriptSetupError, err.Error())
 	}
+	defer plugins.ClosePlugins()
+	closeOnSignal()
+
+	switch command {
+	case "steps":
+		if err := listSteps(os.Stdout, opts.Steps.Format); err != nil {
+			common.AppLogger.Fatal(common.ScriptSetupError, err.Error())
+		}
+		if err := checkStepConflicts(opts.Tests, opts.StrictSteps); err != nil {
+			common.AppLogger.Fatal(common.StepDefinitionError, err.Error())
+		}
+		return
+	case "lock":
+		if err := plugins.WriteLock(opts.LockFile); err != nil {
+			common.AppLogger.Fatal(common.SetupError, err.Error())
+		}
+		return
+	case "repl":
+		if err := runRepl(os.Stdin, os.Stdout); err != nil {
+			common.AppLogger.Fatal(common.ScriptSetupError, err.Error())
+		}
+		return
+	}
+
+	if opts.DryRun {
+		common.AppLogger.Info("validating features without running steps")
+		ok, err := dryRun(os.Stdout, opts.Tests)
+		if err != nil {
+			common.AppLogger.Fatal(common.StepDefinitionError, err.Error())
+		}
+		if !ok {
+			exit(common.StepDefinitionError)
+		}
+		return
+	}
+
+	common.AppLogger.Info("checking step definitions for conflicts")
+	if err := checkStepConflicts(opts.Tests, opts.StrictSteps); err != nil {
+		common.AppLogger.Fatal(common.StepDefinitionError, err.Error())
+	}
 
 	godogOpts := &godog.Options{
 		Paths:  opts.Tests,
@@ -52,12 +152,47 @@ func main() {
 
 	godog.BindCommandLineFlags("godog.", godogOpts)
 
+	status := runSuite(godogOpts)
+
+	if opts.Watch {
+		watch(godogOpts)
+	}
+
+	common.AppLogger.Info(status)
+}
+
+func runSuite(godogOpts *godog.Options) int {
+	undefinedSteps = nil
+
+	runOpts := *godogOpts
+	runOpts.Format = redactFormats(runOpts.Format)
 	status := godog.TestSuite{
 		Name:                 opts.TestName,
 		TestSuiteInitializer: InitializeTestSuite,
 		ScenarioInitializer:  InitializeScenario,
-		Options:              godogOpts,
+		Options:              &runOpts,
 	}.Run()
 
-	common.AppLogger.Info(status)
+	// snippets go to stderr so they don't corrupt reports written to stdout
+	if err := writeSnippets(os.Stderr); err != nil {
+		common.AppLogger.Error(err.Error())
+	}
+
+	return status
+}
+
+// exit closes plugins before exiting, as os.Exit skips deferred calls
+func exit(code int) {
+	plugins.ClosePlugins()
+	os.Exit(code)
+}
+
+func closeOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		received := <-signals
+		common.AppLogger.Info("received %s, closing plugins", received)
+		exit(common.Interrupted)
+	}()
 }
@@ -0,0 +1,82 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/marmotherder/habitable/common"
+)
+
+const (
+	MinPluginAPIVersion = 1
+	PluginAPIVersion    = 1
+
+	pluginInfoSymbol = "HabitablePluginInfo"
+)
+
+var pluginCapabilities = map[string]bool{
+	"object":    true,
+	"lifecycle": true,
+	"steps":     true,
+}
+
+type PluginInfo struct {
+	APIVersion   int      `json:"apiVersion"`
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	Capabilities []string `json:"capabilities"`
+}
+
+func parsePluginInfo(name string, values interface{}) (*PluginInfo, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin info for %s: %s", name, err.Error())
+	}
+	info := &PluginInfo{}
+	if err := json.Unmarshal(encoded, info); err != nil {
+		return nil, fmt.Errorf("failed to read plugin info for %s: %s", name, err.Error())
+	}
+	if info.APIVersion == 0 {
+		return nil, fmt.Errorf("plugin %s declares plugin info without an apiVersion", name)
+	}
+	return info, nil
+}
+
+func checkPluginInfo(name string, data HabitablePluginData, info *PluginInfo) error {
+	if info == nil {
+		common.AppLogger.Warn("plugin %s does not declare %s, assuming plugin API v%d", name, pluginInfoSymbol, MinPluginAPIVersion)
+		return nil
+	}
+
+	common.AppLogger.Debug("plugin %s reports name %s, version %s, API v%d and capabilities %s", name, info.Name, info.Version, info.APIVersion, info.Capabilities)
+	if info.APIVersion < MinPluginAPIVersion {
+		return fmt.Errorf("plugin %s was built for plugin API v%d but this habitable supports v%d-v%d, rebuild the plugin against a newer habitable", name, info.APIVersion, MinPluginAPIVersion, PluginAPIVersion)
+	}
+	if info.APIVersion > PluginAPIVersion {
+		return fmt.Errorf("plugin %s was built for plugin API v%d but this habitable supports v%d-v%d, upgrade habitable or use an older plugin version", name, info.APIVersion, MinPluginAPIVersion, PluginAPIVersion)
+	}
+
+	unsupported := []string{}
+	for _, capability := range info.Capabilities {
+		if !pluginCapabilities[capability] {
+			unsupported = append(unsupported, capability)
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("plugin %s requires capabilities %s that this habitable does not support", name, strings.Join(unsupported, ", "))
+	}
+
+	if info.Name != "" && info.Name != name {
+		common.AppLogger.Warn("plugin %s reports its name as %s", name, info.Name)
+	}
+	if info.Version != "" && data.Version != "" && strings.TrimPrefix(info.Version, "v") != strings.TrimPrefix(data.Version, "v") {
+		return fmt.Errorf("plugin %s reports version %s but version %s was requested", name, info.Version, data.Version)
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"golang.org/x/mod/module"
+
+	"github.com/marmotherder/habitable/command"
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/copy"
+	"github.com/marmotherder/habitable/hashes"
+)
+
+func pluginSource(name string, data HabitablePluginData) (string, error) {
+	if !data.Module {
+		return filepath.Abs(data.Source)
+	}
+
+	modCache, _, err := command.RunCommand(".", "go", "env", "GOMODCACHE")
+	if err != nil {
+		common.AppLogger.Error("failed to find the go module cache for plugin %s", name)
+		return "", err
+	}
+	escaped, err := module.EscapePath(data.Source)
+	if err != nil {
+		return "", err
+	}
+	version := data.Version
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+
+	dir := filepath.Join(strings.TrimSpace(modCache), escaped+"@"+version)
+	if !copy.Exists(dir) {
+		return "", fmt.Errorf("module %s@%s for plugin %s is not in the module cache at %s, run 'go mod download %s@%s'", data.Source, version, name, dir, data.Source, version)
+	}
+	return dir, nil
+}
+
+func buildPlugin(name string, data HabitablePluginData) (string, string, error) {
+	source, err := pluginSource(name, data)
+	if err != nil {
+		return "", "", err
+	}
+	checksum, err := hashes.HashDirectory(source)
+	if err != nil {
+		common.AppLogger.Error("failed to hash plugin source for %s", name)
+		return "", "", err
+	}
+
+	env, err := pluginBuildEnv(name)
+	if err != nil {
+		return "", "", err
+	}
+	flags := pluginBuildFlags()
+
+	// builds are cached by what went into them, a build only exists once go
+	// build succeeds so a failed one is simply retried on the next run
+	buildKey := sha256.Sum256([]byte(strings.Join(append([]string{checksum, runtime.Version()}, flags...), "\n")))
+	cacheDir, err := filepath.Abs(filepath.Join(common.TempPluginsDir(), "source", name))
+	if err != nil {
+		return "", "", err
+	}
+	pluginFile := filepath.Join(cacheDir, hex.EncodeToString(buildKey[:8])+pluginExtensions[SharedObjectPlugin])
+	if copy.Exists(pluginFile) {
+		common.AppLogger.Debug("plugin %s is already built from %s", name, source)
+		return pluginFile, checksum, nil
+	}
+
+	buildDir := filepath.Join(common.TempBuildDir(), "plugins", name)
+	common.AppLogger.Info("building plugin %s from %s", name, source)
+	if err := os.RemoveAll(buildDir); err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(buildDir, 0755); err != nil {
+		return "", "", err
+	}
+	if err := copy.CopyDirectory(source, buildDir); err != nil {
+		common.AppLogger.Error("failed to copy plugin source for %s", name)
+		return "", "", err
+	}
+	if err := makeWritable(buildDir); err != nil {
+		return "", "", err
+	}
+
+	if err := alignPluginModules(name, buildDir, env); err != nil {
+		return "", "", err
+	}
+
+	common.AppLogger.Debug("removing earlier builds of plugin %s", name)
+	if err := os.RemoveAll(cacheDir); err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return "", "", err
+	}
+	args := append([]string{"build", "-buildmode=plugin", "-mod=mod"}, flags...)
+	args = append(args, "-o", pluginFile, ".")
+	if _, stdErr, err := command.RunCommandWithEnv(buildDir, env, "go", args...); err != nil {
+		common.AppLogger.Error("failed to build plugin %s", name)
+		os.Remove(pluginFile)
+		return "", "", fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(stdErr))
+	}
+	common.AppLogger.Info("successfully built plugin %s to %s", name, pluginFile)
+
+	return pluginFile, checksum, nil
+}
+
+// pluginBuildEnv asks for habitable's own toolchain when the installed one
+// differs, go only honours GOTOOLCHAIN from 1.21 so the version actually
+// selected is checked rather than trusted
+func pluginBuildEnv(name string) ([]string, error) {
+	env := append(os.Environ(), "CGO_ENABLED=1")
+	version, err := goVersion(env)
+	if err != nil {
+		common.AppLogger.Error("failed to find the go toolchain version for plugin %s", name)
+		return nil, err
+	}
+	if version == runtime.Version() {
+		return env, nil
+	}
+
+	common.AppLogger.Debug("go toolchain %s does not match habitable's %s", version, runtime.Version())
+	env = append(env, "GOTOOLCHAIN="+runtime.Version())
+	if version, err = goVersion(env); err != nil || version != runtime.Version() {
+		return nil, fmt.Errorf("plugin %s must be built with habitable's go toolchain %s but %s is installed and cannot switch to it, install go %s to build plugins from source", name, runtime.Version(), version, strings.TrimPrefix(runtime.Version(), "go"))
+	}
+	return env, nil
+}
+
+func goVersion(env []string) (string, error) {
+	version, stdErr, err := command.RunCommandWithEnv(".", env, "go", "env", "GOVERSION")
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(stdErr))
+	}
+	return strings.TrimSpace(version), nil
+}
+
+func pluginBuildFlags() []string {
+	flags := []string{}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return flags
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "-trimpath":
+			if setting.Value == "true" {
+				flags = append(flags, "-trimpath")
+			}
+		case "-tags":
+			flags = append(flags, "-tags", setting.Value)
+		}
+	}
+	return flags
+}
+
+func alignPluginModules(name, buildDir string, env []string) error {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		common.AppLogger.Warn("habitable has no build info, plugin %s is built with its own module versions", name)
+		return nil
+	}
+
+	modules, stdErr, err := command.RunCommandWithEnv(buildDir, env, "go", "list", "-mod=mod", "-m", "-f", "{{.Path}}", "all")
+	if err != nil {
+		common.AppLogger.Error("failed to list modules of plugin %s", name)
+		return fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(stdErr))
+	}
+	used := map[string]bool{}
+	for _, path := range strings.Fields(modules) {
+		used[path] = true
+	}
+
+	args := []string{"mod", "edit"}
+	deps := info.Deps
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		deps = append(deps, &info.Main)
+	}
+	for _, dep := range deps {
+		if !used[dep.Path] {
+			continue
+		}
+		common.AppLogger.Trace("aligning plugin %s module %s to %s", name, dep.Path, dep.Version)
+		args = append(args, "-require="+dep.Path+"@"+dep.Version)
+		if dep.Replace != nil {
+			replacement := dep.Replace.Path
+			if dep.Replace.Version != "" {
+				replacement += "@" + dep.Replace.Version
+			}
+			args = append(args, "-replace="+dep.Path+"="+replacement)
+		}
+	}
+	if len(args) == 2 {
+		return nil
+	}
+
+	if _, stdErr, err := command.RunCommandWithEnv(buildDir, env, "go", args...); err != nil {
+		common.AppLogger.Error("failed to align modules of plugin %s", name)
+		return fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(stdErr))
+	}
+	return nil
+}
+
+func makeWritable(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chmod(path, info.Mode()|0200)
+	})
+}
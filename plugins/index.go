@@ -0,0 +1,206 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/marmotherder/habitable/common"
+)
+
+type PluginIndex struct {
+	Plugins map[string]IndexedPlugin `json:"plugins"`
+}
+
+type IndexedPlugin struct {
+	Versions map[string]IndexedVersion `json:"versions"`
+}
+
+type IndexedVersion struct {
+	Type      string                     `json:"type"`
+	Platforms map[string]IndexedPlatform `json:"platforms"`
+}
+
+type IndexedPlatform struct {
+	Location string `json:"location"`
+	Sha256   string `json:"sha256"`
+}
+
+const anyPlatform = "any"
+
+var (
+	Indexes       []string
+	loadedIndexes map[string]*PluginIndex
+)
+
+// indexLocations lists the configured indexes followed by the index.json at
+// the root of each mirror, for a templated mirror that is the directory
+// holding the first %s
+func indexLocations() []string {
+	mirrors := Mirrors
+	if len(mirrors) == 0 {
+		mirrors = []string{DefaultMirror}
+	}
+
+	locations := append([]string{}, Indexes...)
+	for _, mirror := range mirrors {
+		if idx := strings.Index(mirror, "%s"); idx >= 0 {
+			mirror = mirror[:strings.LastIndex(mirror[:idx], "/")+1]
+			if strings.HasSuffix(mirror, "//") {
+				common.AppLogger.Debug("templated mirror has no directory to hold an index.json")
+				continue
+			}
+		}
+		location := "index.json"
+		if mirror != "" {
+			location = strings.TrimSuffix(mirror, "/") + "/" + location
+		}
+		if !containsString(locations, location) {
+			locations = append(locations, location)
+		}
+	}
+	return locations
+}
+
+func containsString(values []string, value string) bool {
+	for _, existing := range values {
+		if existing == value {
+			return true
+		}
+	}
+	return false
+}
+
+func loadIndex(location string) (*PluginIndex, error) {
+	if index, ok := loadedIndexes[location]; ok {
+		return index, nil
+	}
+
+	var contents []byte
+	var err error
+	if isURL(location) {
+		contents, err = downloadIndex(location)
+	} else {
+		common.AppLogger.Debug("reading plugin index from %s", location)
+		contents, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index := &PluginIndex{}
+	if err := json.Unmarshal(contents, index); err != nil {
+		return nil, fmt.Errorf("failed to parse plugin index %s: %s", location, err.Error())
+	}
+	loadedIndexes[location] = index
+	return index, nil
+}
+
+func downloadIndex(location string) ([]byte, error) {
+	common.AppLogger.Debug("downloading plugin index from %s", location)
+	resp, err := pluginClient.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading plugin index from %s returned %s", location, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func indexedLocation(indexLocation, location string) string {
+	if isURL(location) || filepath.IsAbs(location) {
+		return location
+	}
+	if isURL(indexLocation) {
+		base, err := url.Parse(indexLocation)
+		if err != nil {
+			return location
+		}
+		relative, err := url.Parse(location)
+		if err != nil {
+			return location
+		}
+		return base.ResolveReference(relative).String()
+	}
+	return filepath.Join(filepath.Dir(indexLocation), location)
+}
+
+func resolveVersionRange(name string, data HabitablePluginData) (HabitablePluginData, error) {
+	if !data.Mirrored {
+		return data, fmt.Errorf("plugin %s requests version range %s, which can only be resolved from a plugin index and not a custom location", name, data.Constraint)
+	}
+
+	locked := ""
+	if Lock != nil {
+		locked = Lock.Plugins[name].Version
+	}
+
+	locations := indexLocations()
+	if len(locations) == 0 {
+		return data, fmt.Errorf("plugin %s requests version range %s but no plugin index is configured, add one with --plugin-index or the plugins.indexes config", name, data.Constraint)
+	}
+
+	failures := []string{}
+	for _, indexLocation := range locations {
+		index, err := loadIndex(indexLocation)
+		if err != nil {
+			common.AppLogger.Warn("failed to load plugin index %s: %s", indexLocation, err.Error())
+			failures = append(failures, err.Error())
+			continue
+		}
+
+		plugin, ok := index.Plugins[name]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s does not list plugin %s", indexLocation, name))
+			continue
+		}
+		versions := []string{}
+		for version := range plugin.Versions {
+			versions = append(versions, version)
+		}
+		version, err := selectVersion(versions, data.Constraint, locked)
+		if err != nil {
+			return data, fmt.Errorf("plugin %s: %s", name, err.Error())
+		}
+		if version == "" {
+			failures = append(failures, fmt.Sprintf("%s has no version of plugin %s matching %s", indexLocation, name, data.Constraint))
+			continue
+		}
+
+		release := plugin.Versions[version]
+		platform, ok := release.Platforms[runtime.GOOS+"/"+runtime.GOARCH]
+		if !ok {
+			platform, ok = release.Platforms[anyPlatform]
+		}
+		if !ok || platform.Location == "" {
+			failures = append(failures, fmt.Sprintf("%s has no %s/%s build of plugin %s %s", indexLocation, runtime.GOOS, runtime.GOARCH, name, version))
+			continue
+		}
+		if release.Type != "" {
+			if _, known := pluginExtensions[release.Type]; !known {
+				return data, fmt.Errorf("plugin %s %s in index %s has unknown type %s", name, version, indexLocation, release.Type)
+			}
+			data.Type = release.Type
+		}
+
+		data.Version = strings.TrimPrefix(version, "v")
+		data.Location = indexedLocation(indexLocation, platform.Location)
+		data.Locations = []string{data.Location}
+		if data.Sha256 == "" {
+			data.Sha256 = strings.ToLower(platform.Sha256)
+		}
+
+		common.AppLogger.Info("resolved plugin %s %s to version %s from %s", name, data.Constraint, data.Version, indexLocation)
+		return data, nil
+	}
+
+	return data, fmt.Errorf("no version of plugin %s matching %s could be resolved: %s", name, data.Constraint, strings.Join(failures, "; "))
+}
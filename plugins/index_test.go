@@ -0,0 +1,61 @@
+package plugins
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/logger"
+)
+
+func TestIndexLocations(t *testing.T) {
+	common.AppLogger = logger.DefaultLogger{}
+	defer func(indexes, mirrors []string) {
+		Indexes, Mirrors = indexes, mirrors
+	}(Indexes, Mirrors)
+
+	tests := []struct {
+		name     string
+		indexes  []string
+		mirrors  []string
+		expected []string
+	}{
+		{"default mirror", nil, nil, []string{DefaultMirror + "/index.json"}},
+		{"mirror directory", nil, []string{"/srv/plugins/"}, []string{"/srv/plugins/index.json"}},
+		{"indexes before mirrors", []string{"https://example.com/index.json"}, []string{"https://mirror.example.com"}, []string{"https://example.com/index.json", "https://mirror.example.com/index.json"}},
+		{"templated mirror", nil, []string{"https://example.com/plugins/v%s/%s.so"}, []string{"https://example.com/plugins/index.json"}},
+		{"relative templated mirror", nil, []string{"%s_%s.so"}, []string{"index.json"}},
+		{"templated host", nil, []string{"https://%s.example.com/plugin.so"}, []string{}},
+		{"duplicates", []string{"/srv/index.json"}, []string{"/srv", "/srv/v%s/%s.so"}, []string{"/srv/index.json"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			Indexes, Mirrors = test.indexes, test.mirrors
+			if locations := indexLocations(); !reflect.DeepEqual(locations, test.expected) {
+				t.Errorf("indexLocations() = %v, want %v", locations, test.expected)
+			}
+		})
+	}
+}
+
+func TestIndexedLocation(t *testing.T) {
+	tests := []struct {
+		index    string
+		location string
+		expected string
+	}{
+		{"https://example.com/plugins/index.json", "v1.0.0/greeter.so", "https://example.com/plugins/v1.0.0/greeter.so"},
+		{"https://example.com/plugins/index.json", "https://cdn.example.com/greeter.so", "https://cdn.example.com/greeter.so"},
+		{"/srv/plugins/index.json", "v1.0.0/greeter.so", "/srv/plugins/v1.0.0/greeter.so"},
+		{"/srv/plugins/index.json", "/opt/greeter.so", "/opt/greeter.so"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.location, func(t *testing.T) {
+			if location := indexedLocation(test.index, test.location); location != test.expected {
+				t.Errorf("indexedLocation(%q, %q) = %q, want %q", test.index, test.location, location, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package plugins
+
+import (
+	"fmt"
+
+	"github.com/marmotherder/habitable/common"
+)
+
+// HabitablePlugin is kept for plugins written against earlier releases, it
+// was never called by habitable.
+//
+// Deprecated: shared object plugins export NewPluginObject, and implement
+// InitPlugin and ClosePlugin to take part in the plugin lifecycle.
+type HabitablePlugin interface {
+	PluginObject() interface{}
+}
+
+type InitPlugin interface {
+	Init(config map[string]interface{}) error
+}
+
+type ClosePlugin interface {
+	Close() error
+}
+
+type scriptFunction = func(args ...interface{}) (interface{}, error)
+
+type activePlugin struct {
+	name  string
+	close func() error
+}
+
+var activePlugins []activePlugin
+
+func initPlugin(name string, object interface{}, config map[string]interface{}) error {
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+
+	var initFn func() error
+	var closeFn func() error
+	switch o := object.(type) {
+	case map[string]interface{}:
+		if fn, ok := o["init"].(scriptFunction); ok {
+			initFn = func() error {
+				_, err := fn(config)
+				return err
+			}
+			delete(o, "init")
+		}
+		if fn, ok := o["close"].(scriptFunction); ok {
+			closeFn = func() error {
+				_, err := fn()
+				return err
+			}
+			delete(o, "close")
+		}
+	default:
+		if p, ok := object.(InitPlugin); ok {
+			initFn = func() error {
+				return p.Init(config)
+			}
+		}
+		if p, ok := object.(ClosePlugin); ok {
+			closeFn = p.Close
+		}
+	}
+
+	if initFn != nil {
+		common.AppLogger.Debug("initialising plugin %s", name)
+		if err := initFn(); err != nil {
+			return fmt.Errorf("failed to initialise plugin %s: %s", name, err.Error())
+		}
+	} else if len(config) > 0 {
+		common.AppLogger.Warn("plugin %s was given config but does not implement Init", name)
+	}
+
+	if closeFn != nil {
+		activePlugins = append(activePlugins, activePlugin{
+			name:  name,
+			close: closeFn,
+		})
+	}
+	return nil
+}
+
+func ClosePlugins() {
+	for idx := len(activePlugins) - 1; idx >= 0; idx-- {
+		common.AppLogger.Debug("closing plugin %s", activePlugins[idx].name)
+		if err := activePlugins[idx].close(); err != nil {
+			common.AppLogger.Error("failed to close plugin %s: %s", activePlugins[idx].name, err.Error())
+		}
+	}
+	activePlugins = nil
+
+	closeRPCPlugins()
+	closeWasmPlugins()
+}
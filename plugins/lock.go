@@ -0,0 +1,107 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/marmotherder/habitable/common"
+)
+
+type LockedPlugin struct {
+	Version    string `json:"version"`
+	Location   string `json:"location"`
+	Sha256     string `json:"sha256,omitempty"`
+	SourceHash string `json:"sourceHash,omitempty"`
+}
+
+type LockFile struct {
+	Plugins map[string]LockedPlugin `json:"plugins"`
+}
+
+var (
+	Lock            *LockFile
+	Frozen          bool
+	ResolvedPlugins map[string]LockedPlugin
+)
+
+func LoadLock(path string) (*LockFile, error) {
+	lock := &LockFile{
+		Plugins: map[string]LockedPlugin{},
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			common.AppLogger.Debug("no lock file found at %s", path)
+			return lock, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(contents, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %s", path, err.Error())
+	}
+	if lock.Plugins == nil {
+		lock.Plugins = map[string]LockedPlugin{}
+	}
+
+	return lock, nil
+}
+
+func WriteLock(path string) error {
+	lock := LockFile{
+		Plugins: ResolvedPlugins,
+	}
+	if lock.Plugins == nil {
+		lock.Plugins = map[string]LockedPlugin{}
+	}
+
+	contents, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	common.AppLogger.Info("writing %d plugins to lock file %s", len(lock.Plugins), path)
+	return os.WriteFile(path, append(contents, '\n'), 0644)
+}
+
+// lockedChecksum returns the sha256 expected of a downloaded plugin, or the
+// go module h1: hash expected of the source of a plugin built from source
+func lockedChecksum(name string, data HabitablePluginData) (string, error) {
+	if Lock == nil {
+		return data.Sha256, nil
+	}
+
+	locked, ok := Lock.Plugins[name]
+	if !ok {
+		if Frozen {
+			return "", fmt.Errorf("plugin %s is not in the lock file, run the lock command to add it", name)
+		}
+		common.AppLogger.Warn("plugin %s is not in the lock file", name)
+		return data.Sha256, nil
+	}
+
+	mismatch := ""
+	switch {
+	case locked.Version != data.Version:
+		mismatch = fmt.Sprintf("version %s but the lock file has %s", data.Version, locked.Version)
+	case !data.Mirrored && locked.Location != data.Location:
+		mismatch = fmt.Sprintf("location %s but the lock file has %s", data.Location, locked.Location)
+	case data.Sha256 != "" && locked.Sha256 != data.Sha256:
+		mismatch = fmt.Sprintf("sha256 %s but the lock file has %s", data.Sha256, locked.Sha256)
+	}
+	if mismatch != "" {
+		if Frozen {
+			return "", fmt.Errorf("plugin %s requested %s", name, mismatch)
+		}
+		common.AppLogger.Warn("plugin %s requested %s, the lock file is out of date", name, mismatch)
+		return data.Sha256, nil
+	}
+
+	if data.Source != "" {
+		return locked.SourceHash, nil
+	}
+	return locked.Sha256, nil
+}
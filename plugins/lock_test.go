@@ -0,0 +1,111 @@
+package plugins
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/logger"
+)
+
+func TestLockRoundTrip(t *testing.T) {
+	common.AppLogger = logger.DefaultLogger{}
+	defer func(resolved map[string]LockedPlugin) {
+		ResolvedPlugins = resolved
+	}(ResolvedPlugins)
+
+	path := filepath.Join(t.TempDir(), "habitable.lock")
+	lock, err := LoadLock(path)
+	if err != nil {
+		t.Fatalf("LoadLock of a missing file returned error: %s", err.Error())
+	}
+	if len(lock.Plugins) != 0 {
+		t.Errorf("LoadLock of a missing file = %v, want no plugins", lock.Plugins)
+	}
+
+	ResolvedPlugins = map[string]LockedPlugin{
+		"greeter": {Version: "1.4.2", Location: "https://example.com/greeter.so", Sha256: "abc"},
+		"steps":   {Version: "1.0.0", Location: "./steps", SourceHash: "h1:def="},
+	}
+	if err := WriteLock(path); err != nil {
+		t.Fatalf("WriteLock returned error: %s", err.Error())
+	}
+	if lock, err = LoadLock(path); err != nil {
+		t.Fatalf("LoadLock returned error: %s", err.Error())
+	}
+	if !reflect.DeepEqual(lock.Plugins, ResolvedPlugins) {
+		t.Errorf("LoadLock = %v, want %v", lock.Plugins, ResolvedPlugins)
+	}
+
+	ResolvedPlugins = nil
+	if err := WriteLock(path); err != nil {
+		t.Fatalf("WriteLock returned error: %s", err.Error())
+	}
+	if lock, err = LoadLock(path); err != nil || lock.Plugins == nil || len(lock.Plugins) != 0 {
+		t.Errorf("LoadLock of an empty lock = %v, %v, want no plugins", lock, err)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLock(path); err == nil {
+		t.Error("LoadLock of an invalid file should return an error")
+	}
+}
+
+func TestLockedChecksum(t *testing.T) {
+	common.AppLogger = logger.DefaultLogger{}
+	defer func(lock *LockFile, frozen bool) {
+		Lock, Frozen = lock, frozen
+	}(Lock, Frozen)
+
+	lock := &LockFile{
+		Plugins: map[string]LockedPlugin{
+			"greeter": {Version: "1.4.2", Location: "/srv/greeter.so", Sha256: "locked"},
+			"steps":   {Version: "1.0.0", Location: "./steps", SourceHash: "h1:locked="},
+		},
+	}
+	greeter := HabitablePluginData{Version: "1.4.2", Location: "/srv/greeter.so"}
+
+	tests := []struct {
+		name     string
+		lock     *LockFile
+		frozen   bool
+		plugin   string
+		data     HabitablePluginData
+		expected string
+		invalid  bool
+	}{
+		{"no lock file", nil, false, "greeter", HabitablePluginData{Sha256: "requested"}, "requested", false},
+		{"locked", lock, false, "greeter", greeter, "locked", false},
+		{"locked source", lock, false, "steps", HabitablePluginData{Version: "1.0.0", Location: "./steps", Source: "./steps"}, "h1:locked=", false},
+		{"not locked", lock, false, "other", HabitablePluginData{Sha256: "requested"}, "requested", false},
+		{"not locked frozen", lock, true, "other", HabitablePluginData{}, "", true},
+		{"version mismatch", lock, false, "greeter", HabitablePluginData{Version: "1.5.0", Location: "/srv/greeter.so"}, "", false},
+		{"version mismatch frozen", lock, true, "greeter", HabitablePluginData{Version: "1.5.0", Location: "/srv/greeter.so"}, "", true},
+		{"location mismatch frozen", lock, true, "greeter", HabitablePluginData{Version: "1.4.2", Location: "/opt/greeter.so"}, "", true},
+		{"mirrored location ignored", lock, true, "greeter", HabitablePluginData{Version: "1.4.2", Location: "https://mirror/greeter.so", Mirrored: true}, "locked", false},
+		{"sha256 mismatch frozen", lock, true, "greeter", HabitablePluginData{Version: "1.4.2", Location: "/srv/greeter.so", Sha256: "other"}, "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			Lock, Frozen = test.lock, test.frozen
+			checksum, err := lockedChecksum(test.plugin, test.data)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("lockedChecksum should return an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("lockedChecksum returned error: %s", err.Error())
+			}
+			if checksum != test.expected {
+				t.Errorf("lockedChecksum = %q, want %q", checksum, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package plugins
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/copy"
+)
+
+const DefaultMirror = "https://github.com/marmotherder/habitable-plugins/releases/download"
+
+var Mirrors []string
+
+var pluginClient = func() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	return &http.Client{Transport: transport}
+}()
+
+func mirrorLocations(name, version, pluginType string) []string {
+	mirrors := Mirrors
+	if len(mirrors) == 0 {
+		mirrors = []string{DefaultMirror}
+	}
+
+	locations := make([]string, len(mirrors))
+	for idx, mirror := range mirrors {
+		if strings.Contains(mirror, "%s") {
+			locations[idx] = expandLocation(mirror, name, version)
+			continue
+		}
+		locations[idx] = fmt.Sprintf("%s/v%s/%s_%s_%s%s", strings.TrimSuffix(mirror, "/"), version, name, runtime.GOOS, runtime.GOARCH, pluginExtensions[pluginType])
+	}
+	return locations
+}
+
+func expandLocation(location, name, version string) string {
+	args := []interface{}{version, name, runtime.GOOS, runtime.GOARCH}
+	n := strings.Count(location, "%s")
+	if n > len(args) {
+		n = len(args)
+	}
+	if n > 0 {
+		location = fmt.Sprintf(location, args[:n]...)
+	}
+	return location
+}
+
+func isURL(location string) bool {
+	u, err := url.ParseRequestURI(location)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "file")
+}
+
+func localPath(location string) (string, bool) {
+	if !isURL(location) {
+		return location, true
+	}
+	if u, _ := url.ParseRequestURI(location); u.Scheme == "file" {
+		return u.Path, true
+	}
+	return "", false
+}
+
+func fetchPlugin(name, location, pluginFile string) error {
+	if !isURL(location) {
+		common.AppLogger.Info("copying plugin %s from %s", name, location)
+		if err := copy.Copy(location, pluginFile); err != nil {
+			return err
+		}
+		common.AppLogger.Info("successfully copied plugin %s to %s", name, pluginFile)
+		return nil
+	}
+
+	common.AppLogger.Info("downloading plugin %s from %s", name, location)
+	resp, err := pluginClient.Get(location)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading plugin %s from %s returned %s", name, location, resp.Status)
+	}
+
+	partialFile := pluginFile + ".partial"
+	out, err := os.Create(partialFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(partialFile)
+
+	_, err = io.Copy(out, resp.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(partialFile, pluginFile); err != nil {
+		return err
+	}
+	common.AppLogger.Info("successfully downloaded plugin %s to %s", name, pluginFile)
+	return nil
+}
+
+func LocalLocations(data HabitablePluginData) []string {
+	paths := []string{}
+	for _, location := range data.Locations {
+		if path, ok := localPath(location); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
@@ -3,9 +3,6 @@ package plugins
 import (
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"os"
 	"plugin"
 	"runtime"
@@ -16,122 +13,343 @@ import (
 	"github.com/marmotherder/habitable/hashes"
 )
 
-type HabitablePlugin interface {
-	PluginObject() interface{}
+type HabitablePluginData struct {
+	Version    string
+	Location   string
+	Locations  []string
+	Mirrored   bool
+	Constraint string
+	Sha256     string
+	Type       string
+	Source     string
+	Module     bool
+	Config     map[string]interface{}
 }
 
-type HabitablePluginData struct {
-	Version  string
-	Location string
+const (
+	SharedObjectPlugin = "so"
+	RPCPlugin          = "rpc"
+	WasmPlugin         = "wasm"
+)
+
+var pluginExtensions = map[string]string{
+	SharedObjectPlugin: ".so",
+	RPCPlugin:          "",
+	WasmPlugin:         ".wasm",
 }
 
 var LoadPlugins map[string]HabitablePluginData
 
-func UsePlugin(name string, version string, customLocation ...string) {
-	location := "https://github.com/marmotherder/habitable-plugins/releases/download/v%s/%s_%s_%s.so"
-	if len(customLocation) > 1 {
-		common.AppLogger.Trace("using custom location for plugin %s", name)
-		location = customLocation[0]
+func UsePlugin(name string, version string, options ...interface{}) {
+	location := ""
+	sha256 := ""
+	pluginType := SharedObjectPlugin
+	source, isModule := "", false
+	var config map[string]interface{}
+	for _, option := range options {
+		switch o := option.(type) {
+		case string:
+			common.AppLogger.Trace("using custom location for plugin %s", name)
+			location = o
+		case map[string]interface{}:
+			if customLocation, ok := o["location"].(string); ok && customLocation != "" {
+				common.AppLogger.Trace("using custom location for plugin %s", name)
+				location = customLocation
+			}
+			if checksum, ok := o["sha256"].(string); ok {
+				sha256 = strings.ToLower(checksum)
+			}
+			if customType, ok := o["type"].(string); ok && customType != "" {
+				pluginType = customType
+			}
+			if dir, ok := o["source"].(string); ok && dir != "" {
+				source = dir
+			}
+			if modulePath, ok := o["module"].(string); ok && modulePath != "" {
+				source, isModule = modulePath, true
+			}
+			if pluginConfig, ok := o["config"].(map[string]interface{}); ok {
+				config = pluginConfig
+			}
+		case nil:
+		default:
+			common.AppLogger.Warn("ignoring unsupported option %v for plugin %s", option, name)
+		}
+	}
+	if _, ok := pluginExtensions[pluginType]; !ok {
+		common.AppLogger.Error("plugin %s has unknown type %s, using %s", name, pluginType, SharedObjectPlugin)
+		pluginType = SharedObjectPlugin
+	}
+	if source == "" && pluginType == SharedObjectPlugin && location != "" {
+		if info, err := os.Stat(location); err == nil && info.IsDir() {
+			common.AppLogger.Trace("plugin %s location is a directory, building it from source", name)
+			source = location
+		}
+	}
+	if source != "" {
+		if sha256 != "" {
+			common.AppLogger.Warn("ignoring sha256 for plugin %s as it is built from source, the lock file records its source hash", name)
+			sha256 = ""
+		}
+		pluginType = SharedObjectPlugin
+		location = source
 	}
-	args := []interface{}{version, name, runtime.GOOS, runtime.GOARCH}
-	n := strings.Count(location, "%s")
-	if n > 0 {
-		location = fmt.Sprintf(location, args[:n]...)
+	locations, mirrored, constraint := []string{}, location == "", ""
+	if source == "" && (isVersionRange(version) || len(Indexes) > 0 && mirrored && isVersionConstraint(version)) {
+		constraint = version
+		common.AppLogger.Debug("plugin %s requests version range %s, resolving it from the plugin index", name, version)
+	} else if mirrored {
+		locations = mirrorLocations(name, version, pluginType)
+		location = locations[0]
+	} else {
+		location = expandLocation(location, name, version)
+		locations = append(locations, location)
 	}
 
-	common.AppLogger.Debug("adding plugin %s to load from %s", name, location)
+	if constraint == "" {
+		common.AppLogger.Debug("adding plugin %s to load from %s", name, strings.Join(locations, ", "))
+	}
 
 	if LoadPlugins == nil {
 		LoadPlugins = make(map[string]HabitablePluginData)
 	}
 
 	LoadPlugins[name] = HabitablePluginData{
-		Version:  version,
-		Location: location,
+		Version:    version,
+		Location:   location,
+		Locations:  locations,
+		Mirrored:   mirrored,
+		Constraint: constraint,
+		Sha256:     sha256,
+		Type:       pluginType,
+		Source:     source,
+		Module:     isModule,
+		Config:     config,
 	}
 }
 
 func ResolvePlugins() (map[string]interface{}, error) {
+	ClosePlugins()
+
 	loadedPlugins := map[string]interface{}{}
+	ResolvedPlugins = map[string]LockedPlugin{}
+	StepPlugins = map[string]StepPlugin{}
+	loadedIndexes = map[string]*PluginIndex{}
 	for name, data := range LoadPlugins {
 		common.AppLogger.Debug("Attempting to resolve plugin %s", name)
-		hasChanges, err := hashes.CheckStringHash(name, data.Location)
+		if data.Constraint != "" {
+			resolved, err := resolveVersionRange(name, data)
+			if err != nil {
+				return nil, err
+			}
+			data = resolved
+		}
+		expected, err := lockedChecksum(name, data)
 		if err != nil {
-			common.AppLogger.Error("failed to lookup hashes for plugin %s", name)
 			return nil, err
 		}
 
-		common.AppLogger.Debug("vendor any plugins not already present")
-		pluginFile, err := vendorPlugins(name, data.Location, hasChanges)
+		hasChanges, err := hashes.StringHashChanged(name, data.Location+expected)
 		if err != nil {
+			common.AppLogger.Error("failed to lookup hashes for plugin %s", name)
 			return nil, err
 		}
 
-		common.AppLogger.Info("loading plugin %s from %s", name, pluginFile)
-		loaded, err := plugin.Open(pluginFile)
-		if err != nil {
-			common.AppLogger.Error("failed to load plugin %s", name)
+		var pluginFile, checksum string
+		if data.Source != "" {
+			if pluginFile, checksum, err = buildPlugin(name, data); err != nil {
+				return nil, err
+			}
+			if expected != "" && checksum != expected {
+				return nil, fmt.Errorf("plugin %s source failed checksum verification, expected %s but got %s", name, expected, checksum)
+			}
+		} else {
+			if pluginFile, err = resolvePluginFile(name, data, expected, hasChanges); err != nil {
+				return nil, err
+			}
+			if checksum, err = hashes.FileSha256(pluginFile); err != nil {
+				common.AppLogger.Error("failed to hash plugin file for %s", name)
+				return nil, err
+			}
+		}
+		// the hash is only saved once the plugin is fetched and verified, so
+		// a failed run fetches it again
+		if err := hashes.SaveStringHash(name, data.Location+expected); err != nil {
+			common.AppLogger.Error("failed to save hashes for plugin %s", name)
 			return nil, err
 		}
-		entry, err := loaded.Lookup("NewPluginObject")
+		resolved := LockedPlugin{
+			Version:  data.Version,
+			Location: data.Location,
+		}
+		if data.Source != "" {
+			resolved.SourceHash = checksum
+		} else {
+			resolved.Sha256 = checksum
+		}
+		ResolvedPlugins[name] = resolved
+
+		common.AppLogger.Info("loading plugin %s from %s", name, pluginFile)
+		var object interface{}
+		var info *PluginInfo
+		switch data.Type {
+		case RPCPlugin:
+			object, info, err = startRPCPlugin(name, pluginFile)
+		case WasmPlugin:
+			object, info, err = startWasmPlugin(name, pluginFile)
+		default:
+			object, err = openSharedPlugin(name, data, pluginFile)
+		}
 		if err != nil {
-			common.AppLogger.Error("failed to find plugin entrypoint %s", name)
 			return nil, err
 		}
-		entryFn, ok := entry.(func() interface{})
-		if !ok {
-			common.AppLogger.Error("failed to load plugin entrypoint for %s", name)
-			return nil, errors.New("type did not match 'func() interface{}'")
+		if data.Type != SharedObjectPlugin {
+			if err := checkPluginInfo(name, data, info); err != nil {
+				return nil, err
+			}
+		}
+		if err := initPlugin(name, object, data.Config); err != nil {
+			return nil, err
 		}
 
-		common.AppLogger.Info("adding plugin %s to script registration loader", name)
-		loadedPlugins[name] = entryFn()
+		if object != nil {
+			common.AppLogger.Info("adding plugin %s to script registration loader", name)
+			loadedPlugins[name] = object
+		}
 	}
 
 	return loadedPlugins, nil
 }
 
-func vendorPlugins(name, location string, vendor bool) (string, error) {
-	pluginFile := ""
-	if _, err := url.ParseRequestURI(location); err == nil {
-		pluginFile = common.TempPluginsDir() + "/" + name + ".so"
-		if !vendor {
-			return pluginFile, nil
-		}
+func resolvePluginFile(name string, data HabitablePluginData, expected string, hasChanges bool) (string, error) {
+	common.AppLogger.Debug("vendor any plugins not already present")
+	pluginFile := pluginPath(name, data.Type)
+	vendor := hasChanges || !copy.Exists(pluginFile) || localPluginChanged(data.Locations, pluginFile)
+	if err := vendorPlugins(name, data.Locations, pluginFile, vendor); err != nil {
+		return "", err
+	}
 
-		common.AppLogger.Info("downloading plugin %s from %s", name, location)
-		resp, err := http.Get(location)
-		if err != nil {
-			common.AppLogger.Error("failed to resolve plugin for %s", name)
-			return pluginFile, err
+	if err := verifyPlugin(name, pluginFile, expected); err != nil {
+		if hasChanges {
+			return "", err
+		}
+		common.AppLogger.Warn("%s, vendoring plugin %s again", err.Error(), name)
+		if err := vendorPlugins(name, data.Locations, pluginFile, true); err != nil {
+			return "", err
 		}
-		defer resp.Body.Close()
+		if err := verifyPlugin(name, pluginFile, expected); err != nil {
+			return "", err
+		}
+	}
 
-		out, err := os.Create(pluginFile)
-		if err != nil {
-			common.AppLogger.Error("failed to create plugin file for %s", name)
-			return pluginFile, err
+	return pluginFile, nil
+}
+
+// openSharedPlugin checks the plugin info before calling into the plugin, as
+// shared objects run inside habitable itself
+func openSharedPlugin(name string, data HabitablePluginData, pluginFile string) (interface{}, error) {
+	loaded, err := plugin.Open(pluginFile)
+	if err != nil {
+		common.AppLogger.Error("failed to load plugin %s", name)
+		if strings.Contains(err.Error(), "different version of package") {
+			return nil, fmt.Errorf("%s, shared object plugins must be built with the same go toolchain as habitable (%s), rebuild the plugin or use an rpc or wasm plugin", err.Error(), runtime.Version())
 		}
-		defer out.Close()
+		return nil, err
+	}
 
-		if _, err = io.Copy(out, resp.Body); err != nil {
-			common.AppLogger.Error("failed to download plugin file for %s", name)
-			return pluginFile, err
+	var info *PluginInfo
+	if infoSymbol, err := loaded.Lookup(pluginInfoSymbol); err == nil {
+		infoFn, ok := infoSymbol.(func() map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("plugin %s exports %s as %T but 'func() map[string]interface{}' is required", name, pluginInfoSymbol, infoSymbol)
 		}
-		common.AppLogger.Info("successfully downloaded plugin %s to %s", name, pluginFile)
-	} else {
-		pluginFile = common.TempPluginsDir() + "/" + name + ".so"
-		if !vendor {
-			return pluginFile, nil
+		if info, err = parsePluginInfo(name, infoFn()); err != nil {
+			return nil, err
 		}
+	}
+	if err := checkPluginInfo(name, data, info); err != nil {
+		return nil, err
+	}
+
+	if err := lookupStepPlugin(name, loaded); err != nil {
+		return nil, err
+	}
+
+	entry, err := loaded.Lookup("NewPluginObject")
+	if _, hasSteps := StepPlugins[name]; err != nil && hasSteps {
+		common.AppLogger.Debug("plugin %s only registers steps", name)
+		return nil, nil
+	}
+	if err != nil {
+		common.AppLogger.Error("failed to find plugin entrypoint %s", name)
+		return nil, err
+	}
+	entryFn, ok := entry.(func() interface{})
+	if !ok {
+		common.AppLogger.Error("failed to load plugin entrypoint for %s", name)
+		return nil, errors.New("type did not match 'func() interface{}'")
+	}
+
+	return entryFn(), nil
+}
+
+func pluginPath(name, pluginType string) string {
+	return common.TempPluginsDir() + "/" + name + pluginExtensions[pluginType]
+}
 
-		common.AppLogger.Info("copying plugin %s from %s", name, location)
-		if err := copy.Copy(location, pluginFile); err != nil {
-			common.AppLogger.Error("failed to copy plugin file for %s", name)
-			return pluginFile, err
+func verifyPlugin(name, pluginFile, expected string) error {
+	if expected == "" {
+		common.AppLogger.Debug("no sha256 given for plugin %s, skipping verification", name)
+		return nil
+	}
+
+	actual, err := hashes.FileSha256(pluginFile)
+	if err != nil {
+		common.AppLogger.Error("failed to hash plugin file for %s", name)
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("plugin %s at %s failed checksum verification, expected sha256 %s but got %s", name, pluginFile, expected, actual)
+	}
+
+	common.AppLogger.Debug("plugin %s matched sha256 %s", name, expected)
+	return nil
+}
+
+func vendorPlugins(name string, locations []string, pluginFile string, vendor bool) error {
+	if !vendor {
+		return nil
+	}
+
+	failures := []string{}
+	for _, location := range locations {
+		if err := fetchPlugin(name, location, pluginFile); err != nil {
+			common.AppLogger.Warn("failed to fetch plugin %s from %s: %s", name, location, err.Error())
+			failures = append(failures, err.Error())
+			continue
 		}
-		common.AppLogger.Info("successfully copied plugin %s to %s", name, pluginFile)
+		return nil
 	}
 
-	return pluginFile, nil
+	common.AppLogger.Error("failed to resolve plugin for %s", name)
+	return fmt.Errorf("plugin %s could not be fetched from any location: %s", name, strings.Join(failures, "; "))
+}
+
+// localPluginChanged compares the vendored copy with a local plugin file, a
+// plugin rebuilt at the same path keeps its location so only its content shows
+// that it changed
+func localPluginChanged(locations []string, pluginFile string) bool {
+	for _, location := range locations {
+		path, ok := localPath(location)
+		if !ok {
+			continue
+		}
+		source, err := hashes.FileSha256(path)
+		if err != nil {
+			continue
+		}
+		vendored, err := hashes.FileSha256(pluginFile)
+		return err != nil || source != vendored
+	}
+	return false
 }
@@ -0,0 +1,121 @@
+package plugins
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/hashes"
+	"github.com/marmotherder/habitable/logger"
+)
+
+func TestResolvePluginFileLocalChanges(t *testing.T) {
+	dir := pluginTestDir(t)
+
+	location := filepath.Join(dir, "greeter")
+	data := HabitablePluginData{Location: location, Locations: []string{location}, Type: RPCPlugin}
+	for _, content := range []string{"hi bob", "hi alice"} {
+		if err := os.WriteFile(location, []byte(content), 0740); err != nil {
+			t.Fatal(err)
+		}
+		pluginFile, err := resolvePluginFile("greeter", data, "", false)
+		if err != nil {
+			t.Fatalf("resolvePluginFile returned error: %s", err.Error())
+		}
+		vendored, err := os.ReadFile(pluginFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(vendored) != content {
+			t.Errorf("vendored plugin = %q after the local plugin changed to %q", vendored, content)
+		}
+	}
+}
+
+func TestResolvePluginsFailedVerification(t *testing.T) {
+	dir := pluginTestDir(t)
+	defer func(load map[string]HabitablePluginData, lock *LockFile) {
+		LoadPlugins, Lock = load, lock
+	}(LoadPlugins, Lock)
+	Lock = nil
+
+	location := filepath.Join(dir, "greeter")
+	if err := os.WriteFile(location, []byte("hi bob"), 0740); err != nil {
+		t.Fatal(err)
+	}
+	expected := "0000000000000000000000000000000000000000000000000000000000000000"
+	LoadPlugins = map[string]HabitablePluginData{
+		"greeter": {Location: location, Locations: []string{location}, Type: RPCPlugin, Sha256: expected},
+	}
+
+	if _, err := ResolvePlugins(); err == nil {
+		t.Fatal("ResolvePlugins should fail checksum verification")
+	}
+	changed, err := hashes.StringHashChanged("greeter", location+expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("plugin hash was saved although the plugin failed verification")
+	}
+}
+
+func TestUsePluginVersions(t *testing.T) {
+	common.AppLogger = logger.DefaultLogger{}
+	defer func(load map[string]HabitablePluginData, indexes, mirrors []string) {
+		LoadPlugins, Indexes, Mirrors = load, indexes, mirrors
+	}(LoadPlugins, Indexes, Mirrors)
+	Mirrors = []string{"/srv/plugins"}
+
+	tests := []struct {
+		version    string
+		indexes    []string
+		mirror     bool
+		constraint string
+	}{
+		{"1.2.3", nil, true, ""},
+		{"1.2", nil, true, ""},
+		{"v1", nil, true, ""},
+		{"^1.2", nil, false, "^1.2"},
+		{"1.x", nil, false, "1.x"},
+		{"1.2", []string{"/srv/index.json"}, false, "1.2"},
+		{"1.2.3", []string{"/srv/index.json"}, true, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.version, func(t *testing.T) {
+			Indexes = test.indexes
+			UsePlugin("greeter", test.version)
+			data := LoadPlugins["greeter"]
+			if data.Constraint != test.constraint {
+				t.Errorf("UsePlugin(%q) constraint = %q, want %q", test.version, data.Constraint, test.constraint)
+			}
+			if location := mirrorLocations("greeter", test.version, SharedObjectPlugin)[0]; test.mirror && data.Location != location {
+				t.Errorf("UsePlugin(%q) location = %q, want the mirror location %q", test.version, data.Location, location)
+			}
+		})
+	}
+}
+
+func pluginTestDir(t *testing.T) string {
+	t.Helper()
+	common.AppLogger = logger.DefaultLogger{}
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+	})
+	for _, path := range []string{common.TempBuildDir(), common.TempPluginsDir()} {
+		if err := os.MkdirAll(path, 0740); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
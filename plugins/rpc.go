@@ -0,0 +1,166 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unicode"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/plugins/rpcplugin"
+)
+
+type rpcPlugin struct {
+	name   string
+	cmd    *exec.Cmd
+	client *rpc.Client
+}
+
+var rpcPlugins []*rpcPlugin
+
+// rpcCloseTimeout is how long a plugin gets to exit after its stdin closes
+var rpcCloseTimeout = 5 * time.Second
+
+type processConn struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+func (c processConn) Close() error {
+	writeErr := c.WriteCloser.Close()
+	if err := c.ReadCloser.Close(); err != nil {
+		return err
+	}
+	return writeErr
+}
+
+func startRPCPlugin(name, pluginFile string) (interface{}, *PluginInfo, error) {
+	if err := os.Chmod(pluginFile, 0750); err != nil {
+		return nil, nil, err
+	}
+	path, err := filepath.Abs(pluginFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	common.AppLogger.Debug("starting rpc plugin %s from %s", name, path)
+	cmd := exec.Command(path)
+	cmd.Stderr = os.Stderr
+	// keep terminal interrupts away from the plugin so habitable can close it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		common.AppLogger.Error("failed to start rpc plugin %s", name)
+		return nil, nil, err
+	}
+
+	p := &rpcPlugin{
+		name:   name,
+		cmd:    cmd,
+		client: jsonrpc.NewClient(processConn{stdout, stdin}),
+	}
+	rpcPlugins = append(rpcPlugins, p)
+
+	infoValues := map[string]interface{}{}
+	if err := p.client.Call(rpcplugin.ServiceName+".Info", struct{}{}, &infoValues); err != nil {
+		common.AppLogger.Error("failed to read info of rpc plugin %s", name)
+		return nil, nil, err
+	}
+	var info *PluginInfo
+	if len(infoValues) > 0 {
+		if info, err = parsePluginInfo(name, infoValues); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	methods := []string{}
+	if err := p.client.Call(rpcplugin.ServiceName+".Methods", struct{}{}, &methods); err != nil {
+		common.AppLogger.Error("failed to list methods of rpc plugin %s", name)
+		return nil, nil, err
+	}
+	common.AppLogger.Debug("rpc plugin %s exposes methods %s", name, methods)
+
+	object := map[string]interface{}{}
+	for _, method := range methods {
+		object[uncapitalise(method)] = p.method(method)
+	}
+
+	return object, info, nil
+}
+
+func (p *rpcPlugin) method(method string) func(args ...interface{}) (interface{}, error) {
+	return func(args ...interface{}) (interface{}, error) {
+		callArgs := rpcplugin.CallArgs{
+			Method: method,
+			Args:   make([]json.RawMessage, len(args)),
+		}
+		for idx, arg := range args {
+			encoded, err := json.Marshal(arg)
+			if err != nil {
+				return nil, fmt.Errorf("argument %d of %s.%s: %s", idx+1, p.name, method, err.Error())
+			}
+			callArgs.Args[idx] = encoded
+		}
+
+		common.AppLogger.Trace("calling rpc plugin method %s.%s", p.name, method)
+		reply := rpcplugin.CallReply{}
+		if err := p.client.Call(rpcplugin.ServiceName+".Call", callArgs, &reply); err != nil {
+			return nil, err
+		}
+		return reply.Result, nil
+	}
+}
+
+func (p *rpcPlugin) close() error {
+	common.AppLogger.Debug("stopping rpc plugin %s", p.name)
+	if err := p.client.Close(); err != nil {
+		common.AppLogger.Debug("failed to close rpc connection to %s: %s", p.name, err.Error())
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(rpcCloseTimeout):
+		common.AppLogger.Warn("rpc plugin %s did not exit within %s, killing it", p.name, rpcCloseTimeout)
+		if err := p.cmd.Process.Kill(); err != nil {
+			return err
+		}
+		return <-done
+	}
+}
+
+func closeRPCPlugins() {
+	for _, p := range rpcPlugins {
+		if err := p.close(); err != nil {
+			common.AppLogger.Warn("rpc plugin %s did not exit cleanly: %s", p.name, err.Error())
+		}
+	}
+	rpcPlugins = nil
+}
+
+func uncapitalise(name string) string {
+	if name == "" {
+		return name
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
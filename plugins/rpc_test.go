@@ -0,0 +1,52 @@
+package plugins
+
+import (
+	"net/rpc/jsonrpc"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/logger"
+)
+
+func TestRPCPluginCloseTimeout(t *testing.T) {
+	common.AppLogger = logger.DefaultLogger{}
+	defer func(timeout time.Duration) {
+		rpcCloseTimeout = timeout
+	}(rpcCloseTimeout)
+	rpcCloseTimeout = 100 * time.Millisecond
+
+	// sleep ignores stdin, like a plugin that never notices the connection closing
+	cmd := exec.Command("sleep", "60")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	p := &rpcPlugin{
+		name:   "sleep",
+		cmd:    cmd,
+		client: jsonrpc.NewClient(processConn{stdout, stdin}),
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- p.close()
+	}()
+	select {
+	case err := <-closed:
+		if err == nil {
+			t.Error("close of a killed plugin should return its exit error")
+		}
+	case <-time.After(5 * time.Second):
+		cmd.Process.Kill()
+		t.Fatal("close did not kill a plugin that ignores its closed stdin")
+	}
+}
@@ -0,0 +1,133 @@
+package rpcplugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"reflect"
+	"sort"
+)
+
+const (
+	ServiceName = "Plugin"
+	APIVersion  = 1
+)
+
+func Info(name, version string, capabilities ...string) map[string]interface{} {
+	if capabilities == nil {
+		capabilities = []string{"object"}
+	}
+	return map[string]interface{}{
+		"apiVersion":   APIVersion,
+		"name":         name,
+		"version":      version,
+		"capabilities": capabilities,
+	}
+}
+
+type CallArgs struct {
+	Method string
+	Args   []json.RawMessage
+}
+
+type CallReply struct {
+	Result interface{}
+}
+
+type Service struct {
+	object reflect.Value
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func (s *Service) Info(_ struct{}, reply *map[string]interface{}) error {
+	if info, ok := s.object.Interface().(interface {
+		HabitablePluginInfo() map[string]interface{}
+	}); ok {
+		*reply = info.HabitablePluginInfo()
+	}
+	return nil
+}
+
+func (s *Service) Methods(_ struct{}, reply *[]string) error {
+	methods := []string{}
+	for idx := 0; idx < s.object.NumMethod(); idx++ {
+		if name := s.object.Type().Method(idx).Name; name != "HabitablePluginInfo" {
+			methods = append(methods, name)
+		}
+	}
+	sort.Strings(methods)
+	*reply = methods
+	return nil
+}
+
+func (s *Service) Call(args CallArgs, reply *CallReply) error {
+	method := s.object.MethodByName(args.Method)
+	if !method.IsValid() {
+		return fmt.Errorf("plugin has no method %s", args.Method)
+	}
+
+	methodType := method.Type()
+	if len(args.Args) < methodType.NumIn() && !(methodType.IsVariadic() && len(args.Args) >= methodType.NumIn()-1) {
+		return fmt.Errorf("method %s expects %d arguments but got %d", args.Method, methodType.NumIn(), len(args.Args))
+	}
+
+	values := []reflect.Value{}
+	for idx, arg := range args.Args {
+		var argType reflect.Type
+		switch {
+		case methodType.IsVariadic() && idx >= methodType.NumIn()-1:
+			argType = methodType.In(methodType.NumIn() - 1).Elem()
+		case idx < methodType.NumIn():
+			argType = methodType.In(idx)
+		default:
+			continue
+		}
+
+		value := reflect.New(argType)
+		if err := json.Unmarshal(arg, value.Interface()); err != nil {
+			return fmt.Errorf("argument %d of %s: %s", idx+1, args.Method, err.Error())
+		}
+		values = append(values, value.Elem())
+	}
+
+	results := method.Call(values)
+	if len(results) > 0 && methodType.Out(len(results)-1) == errorType {
+		if err, _ := results[len(results)-1].Interface().(error); err != nil {
+			return err
+		}
+		results = results[:len(results)-1]
+	}
+	if len(results) > 0 {
+		reply.Result = results[0].Interface()
+	}
+
+	return nil
+}
+
+type stdio struct {
+	io.Reader
+	io.Writer
+}
+
+func (stdio) Close() error {
+	return nil
+}
+
+func Serve(object interface{}) error {
+	if object == nil {
+		return errors.New("cannot serve a nil plugin object")
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName(ServiceName, &Service{object: reflect.ValueOf(object)}); err != nil {
+		return err
+	}
+
+	server.ServeCodec(jsonrpc.NewServerCodec(stdio{os.Stdin, os.Stdout}))
+	return nil
+}
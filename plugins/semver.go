@@ -0,0 +1,281 @@
+package plugins
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+type versionMatcher func(version string) bool
+
+func canonicalVersion(version string) string {
+	return "v" + strings.TrimPrefix(strings.TrimSpace(version), "v")
+}
+
+func isExactVersion(version string) bool {
+	canonical := canonicalVersion(version)
+	if !semver.IsValid(canonical) {
+		return false
+	}
+	core := strings.SplitN(strings.SplitN(canonical, "+", 2)[0], "-", 2)[0]
+	return strings.Count(core, ".") == 2
+}
+
+// isVersionRange only accepts versions written as ranges, partial versions
+// such as 1.2 are valid constraints but are requested from mirrors as given
+func isVersionRange(version string) bool {
+	return strings.ContainsAny(version, "<>=~^xX*| ") && isVersionConstraint(version)
+}
+
+func isVersionConstraint(version string) bool {
+	if version == "" || isExactVersion(version) {
+		return false
+	}
+	_, err := parseVersionRange(version)
+	return err == nil
+}
+
+type partialVersion struct {
+	numbers [3]int
+	parts   int
+	pre     string
+}
+
+func parsePartialVersion(text string) (partialVersion, error) {
+	version := partialVersion{}
+	text = strings.TrimPrefix(strings.TrimSpace(text), "v")
+	if idx := strings.Index(text, "+"); idx >= 0 {
+		text = text[:idx]
+	}
+	if idx := strings.Index(text, "-"); idx >= 0 {
+		version.pre = text[idx:]
+		text = text[:idx]
+	}
+
+	for idx, part := range strings.Split(text, ".") {
+		if idx > 2 {
+			return version, fmt.Errorf("version %s has too many parts", text)
+		}
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return version, fmt.Errorf("version %s is not a valid semantic version", text)
+		}
+		version.numbers[idx] = number
+		version.parts++
+	}
+	if version.pre != "" && version.parts < 3 {
+		return version, fmt.Errorf("version %s has a prerelease without a patch version", text)
+	}
+
+	return version, nil
+}
+
+func (v partialVersion) String() string {
+	return fmt.Sprintf("v%d.%d.%d%s", v.numbers[0], v.numbers[1], v.numbers[2], v.pre)
+}
+
+// bump returns the first version past the given number of leading parts,
+// so bump(1) of 1.4.2 is 2.0.0 and bump(2) is 1.5.0
+func (v partialVersion) bump(parts int) string {
+	next := partialVersion{}
+	copy(next.numbers[:parts], v.numbers[:parts])
+	next.numbers[parts-1]++
+	return next.String()
+}
+
+func between(lower, upper string) versionMatcher {
+	return func(version string) bool {
+		return semver.Compare(version, lower) >= 0 && (upper == "" || semver.Compare(version, upper) < 0)
+	}
+}
+
+const versionOperators = "^~<>="
+
+func parseComparator(text string) (versionMatcher, partialVersion, error) {
+	operand := strings.TrimLeft(text, versionOperators)
+	operator := text[:len(text)-len(operand)]
+	if operand == "" {
+		operand = "*"
+	}
+	version, err := parsePartialVersion(operand)
+	if err != nil {
+		return nil, version, err
+	}
+	lower := version.String()
+	matchAll := func(string) bool { return true }
+	matchNone := func(string) bool { return false }
+
+	switch operator {
+	case "^":
+		switch {
+		case version.parts == 0:
+			return matchAll, version, nil
+		case version.numbers[0] > 0 || version.parts == 1:
+			return between(lower, version.bump(1)), version, nil
+		case version.numbers[1] > 0 || version.parts == 2:
+			return between(lower, version.bump(2)), version, nil
+		default:
+			return between(lower, version.bump(3)), version, nil
+		}
+	case "~":
+		if version.parts == 0 {
+			return matchAll, version, nil
+		}
+		if version.parts == 1 {
+			return between(lower, version.bump(1)), version, nil
+		}
+		return between(lower, version.bump(2)), version, nil
+	case "", "=":
+		if version.parts == 0 {
+			return matchAll, version, nil
+		}
+		if version.parts < 3 {
+			return between(lower, version.bump(version.parts)), version, nil
+		}
+		return func(candidate string) bool {
+			return semver.Compare(candidate, lower) == 0
+		}, version, nil
+	case ">=":
+		if version.parts == 0 {
+			return matchAll, version, nil
+		}
+		return func(candidate string) bool { return semver.Compare(candidate, lower) >= 0 }, version, nil
+	case ">":
+		if version.parts == 0 {
+			return matchNone, version, nil
+		}
+		// >1.2 excludes every 1.2.x, so it starts from the next minor
+		if version.parts < 3 {
+			next := version.bump(version.parts)
+			return func(candidate string) bool { return semver.Compare(candidate, next) >= 0 }, version, nil
+		}
+		return func(candidate string) bool { return semver.Compare(candidate, lower) > 0 }, version, nil
+	case "<=":
+		if version.parts == 0 {
+			return matchAll, version, nil
+		}
+		// <=1.2 includes every 1.2.x, so it ends before the next minor
+		if version.parts < 3 {
+			next := version.bump(version.parts)
+			return func(candidate string) bool { return semver.Compare(candidate, next) < 0 }, version, nil
+		}
+		return func(candidate string) bool { return semver.Compare(candidate, lower) <= 0 }, version, nil
+	case "<":
+		if version.parts == 0 {
+			return matchNone, version, nil
+		}
+		return func(candidate string) bool { return semver.Compare(candidate, lower) < 0 }, version, nil
+	}
+
+	return nil, version, fmt.Errorf("unknown version operator %s in %s", operator, text)
+}
+
+// comparatorFields splits a comparator set on spaces, keeping an operator
+// written apart from its version such as ">= 1.2.0" as one comparator
+func comparatorFields(alternative string) []string {
+	fields := []string{}
+	pending := ""
+	for _, field := range strings.Fields(alternative) {
+		if strings.Trim(field, versionOperators) == "" {
+			pending += field
+			continue
+		}
+		fields = append(fields, pending+field)
+		pending = ""
+	}
+	if pending != "" {
+		fields = append(fields, pending)
+	}
+	return fields
+}
+
+type comparatorSet struct {
+	comparators []versionMatcher
+	// prereleases are only matched for versions sharing the major, minor and
+	// patch of a comparator that names a prerelease, as npm does
+	prereleases map[string]bool
+}
+
+func (c comparatorSet) matches(version string) bool {
+	if semver.Prerelease(version) != "" && !c.prereleases[strings.SplitN(semver.Canonical(version), "-", 2)[0]] {
+		return false
+	}
+	for _, comparator := range c.comparators {
+		if !comparator(version) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseVersionRange accepts npm style ranges, comparators separated by
+// spaces must all match and alternatives are separated by ||
+func parseVersionRange(constraint string) (versionMatcher, error) {
+	alternatives := []comparatorSet{}
+	for _, alternative := range strings.Split(constraint, "||") {
+		set := comparatorSet{
+			prereleases: map[string]bool{},
+		}
+		for _, field := range comparatorFields(alternative) {
+			comparator, version, err := parseComparator(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version range %s: %s", constraint, err.Error())
+			}
+			set.comparators = append(set.comparators, comparator)
+			if version.pre != "" {
+				set.prereleases[fmt.Sprintf("v%d.%d.%d", version.numbers[0], version.numbers[1], version.numbers[2])] = true
+			}
+		}
+		if len(set.comparators) == 0 {
+			return nil, fmt.Errorf("invalid version range %s: empty range", constraint)
+		}
+		alternatives = append(alternatives, set)
+	}
+
+	return func(version string) bool {
+		version = canonicalVersion(version)
+		if !semver.IsValid(version) {
+			return false
+		}
+		for _, set := range alternatives {
+			if set.matches(version) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func selectVersion(versions []string, constraint, locked string) (string, error) {
+	matches, err := parseVersionRange(constraint)
+	if err != nil {
+		return "", err
+	}
+
+	candidates := []string{}
+	for _, version := range versions {
+		if matches(version) {
+			candidates = append(candidates, version)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", nil
+	}
+
+	for _, candidate := range candidates {
+		if locked != "" && semver.Compare(canonicalVersion(candidate), canonicalVersion(locked)) == 0 {
+			return candidate, nil
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return semver.Compare(canonicalVersion(candidates[i]), canonicalVersion(candidates[j])) > 0
+	})
+	return candidates[0], nil
+}
@@ -0,0 +1,130 @@
+package plugins
+
+import (
+	"testing"
+)
+
+func TestIsVersionRange(t *testing.T) {
+	tests := []struct {
+		version string
+		exact   bool
+		isRange bool
+	}{
+		{"1.2.3", true, false},
+		{"v1.2.3", true, false},
+		{"1.2.3-beta.1", true, false},
+		{"1.2", false, false},
+		{"v1", false, false},
+		{"1.2.x", false, true},
+		{"1 || 2", false, true},
+		{"^1.2.3", false, true},
+		{"~1.2", false, true},
+		{">= 1.2.0 < 2", false, true},
+		{"1.x || 2.x", false, true},
+		{"", false, false},
+		{"latest", false, false},
+		{"1.2.3.4", false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.version, func(t *testing.T) {
+			if exact := isExactVersion(test.version); exact != test.exact {
+				t.Errorf("isExactVersion(%q) = %t, want %t", test.version, exact, test.exact)
+			}
+			if isRange := isVersionRange(test.version); isRange != test.isRange {
+				t.Errorf("isVersionRange(%q) = %t, want %t", test.version, isRange, test.isRange)
+			}
+		})
+	}
+}
+
+func TestParseVersionRange(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		rejects    []string
+	}{
+		{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"1.2.2", "2.0.0"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^1.2", []string{"1.2.0", "1.9.9"}, []string{"1.1.9", "2.0.0"}},
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0"}},
+		{"~1", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+		{"1.2", []string{"1.2.0", "1.2.5"}, []string{"1.3.0"}},
+		{"1.x", []string{"1.0.0", "1.5.0"}, []string{"2.0.0"}},
+		{"*", []string{"0.0.1", "9.9.9"}, nil},
+		{"=1.2.3", []string{"1.2.3"}, []string{"1.2.4"}},
+		{">=1.2.0", []string{"1.2.0", "3.0.0"}, []string{"1.1.9"}},
+		{">= 1.2.0", []string{"1.2.0", "3.0.0"}, []string{"1.1.9"}},
+		{">= 1.2.0 < 2", []string{"1.2.0", "1.9.9"}, []string{"1.1.0", "2.0.0"}},
+		{">1.2.3", []string{"1.2.4"}, []string{"1.2.3"}},
+		{">1.2", []string{"1.3.0"}, []string{"1.2.0", "1.2.9"}},
+		{">1", []string{"2.0.0"}, []string{"1.9.9"}},
+		{"<1.2", []string{"1.1.9"}, []string{"1.2.0"}},
+		{"<=1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0"}},
+		{"<=1.2.3", []string{"1.2.3"}, []string{"1.2.4"}},
+		{"<*", nil, []string{"0.0.1"}},
+		{"1.x || >=3.1", []string{"1.4.0", "3.1.0"}, []string{"2.0.0", "3.0.0"}},
+		{"^1.2.3", nil, []string{"1.3.0-beta.1", "1.2.3-beta.1"}},
+		{"^1.2.3-beta.2", []string{"1.2.3-beta.2", "1.2.3-rc.1", "1.2.3", "1.4.0"}, []string{"1.2.3-beta.1", "1.2.4-beta.1"}},
+		{">=1.2.3-beta <1.3.0 || 2.x", []string{"1.2.3-beta.1", "2.1.0"}, []string{"2.1.0-beta.1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.constraint, func(t *testing.T) {
+			matches, err := parseVersionRange(test.constraint)
+			if err != nil {
+				t.Fatalf("parseVersionRange(%q) returned error: %s", test.constraint, err.Error())
+			}
+			for _, version := range test.matches {
+				if !matches(version) {
+					t.Errorf("%q should match %s", test.constraint, version)
+				}
+			}
+			for _, version := range test.rejects {
+				if matches(version) {
+					t.Errorf("%q should not match %s", test.constraint, version)
+				}
+			}
+		})
+	}
+}
+
+func TestParseVersionRangeErrors(t *testing.T) {
+	for _, constraint := range []string{"", "1.2 ||", "^a.b", "1.2-beta", "!1.2.3", "1.2.3.4"} {
+		t.Run(constraint, func(t *testing.T) {
+			if _, err := parseVersionRange(constraint); err == nil {
+				t.Errorf("parseVersionRange(%q) should return an error", constraint)
+			}
+		})
+	}
+}
+
+func TestSelectVersion(t *testing.T) {
+	versions := []string{"1.0.0", "v1.4.2", "1.5.0", "2.0.0-beta.1", "2.0.0"}
+	tests := []struct {
+		constraint string
+		locked     string
+		expected   string
+	}{
+		{"^1.0.0", "", "1.5.0"},
+		{"^1.0.0", "1.4.2", "v1.4.2"},
+		{"^1.0.0", "v1.0.0", "1.0.0"},
+		{"^1.0.0", "1.3.0", "1.5.0"},
+		{"~1.4", "", "v1.4.2"},
+		{">=2.0.0-beta.1", "", "2.0.0"},
+		{"^3", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.constraint+"@"+test.locked, func(t *testing.T) {
+			selected, err := selectVersion(versions, test.constraint, test.locked)
+			if err != nil {
+				t.Fatalf("selectVersion returned error: %s", err.Error())
+			}
+			if selected != test.expected {
+				t.Errorf("selectVersion(%q, %q) = %q, want %q", test.constraint, test.locked, selected, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package stepplugin
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/cucumber/godog"
+)
+
+// ScenarioContext is handed to the InitializeScenario of a plugin, steps
+// registered on it are passed to godog during a run and recorded so
+// habitable can list them without a run
+type ScenarioContext struct {
+	ctx   *godog.ScenarioContext
+	steps []Step
+}
+
+type Step struct {
+	Pattern string
+	Handler interface{}
+}
+
+func NewScenarioContext(ctx *godog.ScenarioContext) *ScenarioContext {
+	return &ScenarioContext{
+		ctx: ctx,
+	}
+}
+
+func (c *ScenarioContext) Step(expr, stepFunc interface{}) {
+	pattern := ""
+	switch e := expr.(type) {
+	case string:
+		pattern = e
+	case *regexp.Regexp:
+		pattern = e.String()
+	case []byte:
+		pattern = string(e)
+	default:
+		panic(fmt.Sprintf("expecting expr to be a *regexp.Regexp or a string, got type: %T", expr))
+	}
+	c.steps = append(c.steps, Step{
+		Pattern: pattern,
+		Handler: stepFunc,
+	})
+
+	if c.ctx != nil {
+		c.ctx.Step(expr, stepFunc)
+	}
+}
+
+func (c *ScenarioContext) Before(hook godog.BeforeScenarioHook) {
+	if c.ctx != nil {
+		c.ctx.Before(hook)
+	}
+}
+
+func (c *ScenarioContext) After(hook godog.AfterScenarioHook) {
+	if c.ctx != nil {
+		c.ctx.After(hook)
+	}
+}
+
+func (c *ScenarioContext) BeforeStep(hook godog.BeforeStepHook) {
+	if c.ctx != nil {
+		c.ctx.StepContext().Before(hook)
+	}
+}
+
+func (c *ScenarioContext) AfterStep(hook godog.AfterStepHook) {
+	if c.ctx != nil {
+		c.ctx.StepContext().After(hook)
+	}
+}
+
+func (c *ScenarioContext) Steps() []Step {
+	return c.steps
+}
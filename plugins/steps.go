@@ -0,0 +1,92 @@
+package plugins
+
+import (
+	"fmt"
+	"plugin"
+
+	"github.com/cucumber/godog"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/plugins/stepplugin"
+)
+
+type StepPlugin struct {
+	InitializeScenario  func(*stepplugin.ScenarioContext)
+	InitializeTestSuite func(*godog.TestSuiteContext)
+}
+
+var StepPlugins map[string]StepPlugin
+
+func lookupStepPlugin(name string, loaded *plugin.Plugin) error {
+	stepPlugin := StepPlugin{}
+	if symbol, err := loaded.Lookup("InitializeScenario"); err == nil {
+		fn, ok := symbol.(func(*stepplugin.ScenarioContext))
+		if !ok {
+			return fmt.Errorf("plugin %s exports InitializeScenario as %T but 'func(*stepplugin.ScenarioContext)' is required, check it is built against the same habitable version", name, symbol)
+		}
+		stepPlugin.InitializeScenario = fn
+	}
+	if symbol, err := loaded.Lookup("InitializeTestSuite"); err == nil {
+		fn, ok := symbol.(func(*godog.TestSuiteContext))
+		if !ok {
+			return fmt.Errorf("plugin %s exports InitializeTestSuite as %T but 'func(*godog.TestSuiteContext)' is required, check it is built against the same godog version as habitable", name, symbol)
+		}
+		stepPlugin.InitializeTestSuite = fn
+	}
+
+	if stepPlugin.InitializeScenario != nil || stepPlugin.InitializeTestSuite != nil {
+		common.AppLogger.Debug("plugin %s registers go steps and hooks", name)
+		StepPlugins[name] = stepPlugin
+	}
+	return nil
+}
+
+func InitializeTestSuite(ctx *godog.TestSuiteContext) {
+	for name, stepPlugin := range StepPlugins {
+		if stepPlugin.InitializeTestSuite != nil {
+			common.AppLogger.Debug("initialising test suite for plugin %s", name)
+			stepPlugin.InitializeTestSuite(ctx)
+		}
+	}
+}
+
+type PluginStep struct {
+	Plugin  string
+	Pattern string
+	Handler interface{}
+}
+
+func CollectSteps() []PluginStep {
+	steps := []PluginStep{}
+	for name, stepPlugin := range StepPlugins {
+		if stepPlugin.InitializeScenario == nil {
+			continue
+		}
+		common.AppLogger.Debug("collecting go steps from plugin %s", name)
+		collected, err := collectPluginSteps(stepPlugin.InitializeScenario)
+		if err != nil {
+			common.AppLogger.Warn("failed to collect steps from plugin %s: %s", name, err.Error())
+			continue
+		}
+		for _, step := range collected {
+			steps = append(steps, PluginStep{
+				Plugin:  name,
+				Pattern: step.Pattern,
+				Handler: step.Handler,
+			})
+		}
+	}
+	return steps
+}
+
+func collectPluginSteps(initializer func(*stepplugin.ScenarioContext)) (steps []stepplugin.Step, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	ctx := stepplugin.NewScenarioContext(nil)
+	initializer(ctx)
+	return ctx.Steps(), nil
+}
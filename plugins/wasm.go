@@ -0,0 +1,201 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/marmotherder/habitable/common"
+)
+
+// wasm plugins export habitable_alloc(size) ptr, habitable_manifest() packed
+// and one func(ptr, size) packed per manifest function, where packed is
+// ptr<<32|size of a JSON document in the module memory. Functions receive a
+// JSON array of arguments and return {"result": ..., "error": "..."}. The
+// manifest lists the functions alongside the plugin info fields.
+const (
+	wasmAllocExport    = "habitable_alloc"
+	wasmFreeExport     = "habitable_free"
+	wasmManifestExport = "habitable_manifest"
+)
+
+type wasmManifest struct {
+	PluginInfo
+	Functions []string `json:"functions"`
+}
+
+type wasmResult struct {
+	Result interface{} `json:"result"`
+	Error  string      `json:"error"`
+}
+
+type wasmPlugin struct {
+	name    string
+	runtime wazero.Runtime
+	module  api.Module
+}
+
+var wasmPlugins []*wasmPlugin
+
+func startWasmPlugin(name, pluginFile string) (interface{}, *PluginInfo, error) {
+	binary, err := os.ReadFile(pluginFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+	p := &wasmPlugin{
+		name:    name,
+		runtime: wazero.NewRuntime(ctx),
+	}
+	wasmPlugins = append(wasmPlugins, p)
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, p.runtime); err != nil {
+		return nil, nil, err
+	}
+
+	common.AppLogger.Debug("instantiating wasm plugin %s from %s", name, pluginFile)
+	config := wazero.NewModuleConfig().
+		WithName(name).
+		WithStderr(os.Stderr).
+		WithStartFunctions("_initialize")
+	if p.module, err = p.runtime.InstantiateWithConfig(ctx, binary, config); err != nil {
+		common.AppLogger.Error("failed to instantiate wasm plugin %s", name)
+		return nil, nil, err
+	}
+
+	manifestFn := p.module.ExportedFunction(wasmManifestExport)
+	if manifestFn == nil {
+		return nil, nil, fmt.Errorf("wasm plugin %s does not export %s", name, wasmManifestExport)
+	}
+	packed, err := p.call(ctx, wasmManifestExport, manifestFn)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifestData, err := p.read(packed)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest := wasmManifest{}
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse manifest of wasm plugin %s: %s", name, err.Error())
+	}
+	common.AppLogger.Debug("wasm plugin %s exposes functions %s", name, manifest.Functions)
+
+	object := map[string]interface{}{}
+	for _, function := range manifest.Functions {
+		if p.module.ExportedFunction(function) == nil {
+			return nil, nil, fmt.Errorf("wasm plugin %s lists %s in its manifest but does not export it", name, function)
+		}
+		object[function] = p.function(function)
+	}
+
+	var info *PluginInfo
+	if manifest.APIVersion > 0 {
+		info = &manifest.PluginInfo
+	}
+
+	return object, info, nil
+}
+
+func (p *wasmPlugin) function(function string) func(args ...interface{}) (interface{}, error) {
+	return func(args ...interface{}) (interface{}, error) {
+		if args == nil {
+			args = []interface{}{}
+		}
+		encoded, err := json.Marshal(args)
+		if err != nil {
+			return nil, fmt.Errorf("arguments of %s.%s: %s", p.name, function, err.Error())
+		}
+
+		ctx := context.Background()
+		ptr, err := p.write(ctx, encoded)
+		if err != nil {
+			return nil, err
+		}
+		defer p.free(ctx, ptr, uint32(len(encoded)))
+
+		common.AppLogger.Trace("calling wasm plugin function %s.%s", p.name, function)
+		packed, err := p.call(ctx, function, p.module.ExportedFunction(function), uint64(ptr), uint64(len(encoded)))
+		if err != nil {
+			return nil, err
+		}
+		data, err := p.read(packed)
+		if err != nil {
+			return nil, err
+		}
+		defer p.free(ctx, uint32(packed>>32), uint32(packed))
+
+		result := wasmResult{}
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse result of %s.%s: %s", p.name, function, err.Error())
+		}
+		if result.Error != "" {
+			return nil, errors.New(result.Error)
+		}
+		return result.Result, nil
+	}
+}
+
+func (p *wasmPlugin) write(ctx context.Context, data []byte) (uint32, error) {
+	alloc := p.module.ExportedFunction(wasmAllocExport)
+	if alloc == nil {
+		return 0, fmt.Errorf("wasm plugin %s does not export %s", p.name, wasmAllocExport)
+	}
+	result, err := p.call(ctx, wasmAllocExport, alloc, uint64(len(data)))
+	if err != nil {
+		return 0, err
+	}
+
+	ptr := uint32(result)
+	if !p.module.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("failed to write %d bytes to wasm plugin %s memory", len(data), p.name)
+	}
+	return ptr, nil
+}
+
+// call runs an export that returns a single value, a module built against a
+// different protocol may return none and must not crash habitable
+func (p *wasmPlugin) call(ctx context.Context, export string, fn api.Function, params ...uint64) (uint64, error) {
+	results, err := fn.Call(ctx, params...)
+	if err != nil {
+		return 0, err
+	}
+	if len(results) != 1 {
+		return 0, fmt.Errorf("wasm plugin %s export %s returned %d values but 1 is required", p.name, export, len(results))
+	}
+	return results[0], nil
+}
+
+func (p *wasmPlugin) read(packed uint64) ([]byte, error) {
+	ptr, size := uint32(packed>>32), uint32(packed)
+	data, ok := p.module.Memory().Read(ptr, size)
+	if !ok {
+		return nil, fmt.Errorf("failed to read %d bytes from wasm plugin %s memory", size, p.name)
+	}
+	return append([]byte{}, data...), nil
+}
+
+func (p *wasmPlugin) free(ctx context.Context, ptr, size uint32) {
+	if free := p.module.ExportedFunction(wasmFreeExport); free != nil {
+		if _, err := free.Call(ctx, uint64(ptr), uint64(size)); err != nil {
+			common.AppLogger.Debug("failed to free wasm plugin %s memory: %s", p.name, err.Error())
+		}
+	}
+}
+
+func closeWasmPlugins() {
+	for _, p := range wasmPlugins {
+		common.AppLogger.Debug("closing wasm plugin %s", p.name)
+		if err := p.runtime.Close(context.Background()); err != nil {
+			common.AppLogger.Warn("wasm plugin %s did not close cleanly: %s", p.name, err.Error())
+		}
+	}
+	wasmPlugins = nil
+}
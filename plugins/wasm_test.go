@@ -0,0 +1,40 @@
+package plugins
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/logger"
+)
+
+func TestStartWasmPluginResultCount(t *testing.T) {
+	common.AppLogger = logger.DefaultLogger{}
+	defer closeWasmPlugins()
+
+	// a module with memory whose habitable_manifest export returns nothing
+	module := []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+		0x01, 0x04, 0x01, 0x60, 0x00, 0x00,
+		0x03, 0x02, 0x01, 0x00,
+		0x05, 0x03, 0x01, 0x00, 0x01,
+		0x07, 0x16, 0x01, 0x12,
+	}
+	module = append(module, []byte(wasmManifestExport)...)
+	module = append(module, 0x00, 0x00, 0x0a, 0x04, 0x01, 0x02, 0x00, 0x0b)
+
+	pluginFile := filepath.Join(t.TempDir(), "empty.wasm")
+	if err := os.WriteFile(pluginFile, module, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := startWasmPlugin("empty", pluginFile)
+	if err == nil {
+		t.Fatal("startWasmPlugin should reject a manifest export without a result")
+	}
+	if !strings.Contains(err.Error(), wasmManifestExport) {
+		t.Errorf("error %q does not name the %s export", err.Error(), wasmManifestExport)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/scripting"
+)
+
+type replCommand struct{}
+
+var replKeywords = []string{"Given ", "When ", "Then ", "And ", "But ", "* "}
+
+const replHelp = `Enter a Gherkin step (e.g. "Given I have 5 cukes") to match and run it,
+or any other line to evaluate it as javascript.
+
+  .steps            list registered step definitions
+  .vars [prefix]    show habitable variables, optionally filtered by prefix
+  .reload           reload scripts and plugins from the extension directories
+  .history          show the input history
+  .help             show this message
+  .exit             leave the repl
+`
+
+func replHistoryFile() string {
+	return filepath.Join(common.TempDir(), "repl_history")
+}
+
+func runRepl(in io.Reader, out io.Writer) error {
+	repl, err := scripting.NewRepl()
+	if err != nil {
+		return err
+	}
+
+	history := []string{}
+	if contents, err := os.ReadFile(replHistoryFile()); err == nil {
+		for _, line := range strings.Split(string(contents), "\n") {
+			if line != "" {
+				history = append(history, line)
+			}
+		}
+	}
+	historyFile, err := os.OpenFile(replHistoryFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	defer historyFile.Close()
+
+	fmt.Fprintf(out, "habitable repl, %d steps loaded, type .help for commands\n", len(repl.Steps()))
+	scanner := bufio.NewScanner(in)
+	for fmt.Fprint(out, "> "); scanner.Scan(); fmt.Fprint(out, "> ") {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		history = append(history, line)
+		fmt.Fprintln(historyFile, line)
+
+		switch {
+		case line == ".exit":
+			return nil
+		case line == ".help":
+			fmt.Fprint(out, replHelp)
+		case line == ".history":
+			for idx, entry := range history {
+				fmt.Fprintf(out, "%4d  %s\n", idx+1, entry)
+			}
+		case line == ".steps":
+			for _, step := range repl.Steps() {
+				fmt.Fprintf(out, "%s (%s)\n", step.Pattern, stepLocation(step))
+			}
+		case strings.HasPrefix(line, ".vars"):
+			printVariables(out, strings.TrimSpace(strings.TrimPrefix(line, ".vars")))
+		case line == ".reload":
+			common.AppLogger.Info("reloading scripts")
+			if err := scripting.LoadScripts(opts.ScriptDirs...); err != nil {
+				fmt.Fprintf(out, "error: %s\n", err.Error())
+				continue
+			}
+			reloaded, err := scripting.NewRepl()
+			if err != nil {
+				fmt.Fprintf(out, "error: %s\n", err.Error())
+				continue
+			}
+			repl = reloaded
+			fmt.Fprintf(out, "reloaded, %d steps loaded\n", len(repl.Steps()))
+		case isReplStep(line):
+			runReplStep(out, repl, line)
+		default:
+			result, err := repl.Eval(line)
+			if err != nil {
+				fmt.Fprintf(out, "error: %s\n", err.Error())
+				continue
+			}
+			if result != "" {
+				fmt.Fprintln(out, result)
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+func isReplStep(line string) bool {
+	for _, keyword := range replKeywords {
+		if strings.HasPrefix(line, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func runReplStep(out io.Writer, repl *scripting.Repl, line string) {
+	text := line
+	for _, keyword := range replKeywords {
+		text = strings.TrimSpace(strings.TrimPrefix(text, keyword))
+	}
+
+	matches, result := repl.RunStep(text)
+	switch {
+	case len(matches) == 0:
+		fmt.Fprintln(out, "undefined: no step definition matches")
+		return
+	case len(matches) > 1:
+		fmt.Fprintln(out, "ambiguous: step matches")
+		for _, match := range matches {
+			fmt.Fprintf(out, "  %s (%s)\n", match.Pattern, stepLocation(match))
+		}
+		return
+	}
+
+	fmt.Fprintf(out, "matched: %s (%s)\n", result.Definition.Pattern, stepLocation(result.Definition))
+	for idx, arg := range result.Args {
+		name := fmt.Sprintf("arg%d", idx+1)
+		if idx < len(result.Definition.Params) {
+			name = result.Definition.Params[idx].Name
+		}
+		fmt.Fprintf(out, "  %s = %q\n", name, arg)
+	}
+	if result.Err != nil {
+		fmt.Fprintf(out, "failed: %s\n", result.Err.Error())
+		return
+	}
+	fmt.Fprintln(out, "passed")
+}
+
+func printVariables(out io.Writer, prefix string) {
+	for _, key := range common.Variables.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			value := common.Variables.Get(key)
+			if common.Variables.IsSecret(key) {
+				value = common.RedactedValue
+			}
+			fmt.Fprintf(out, "%s=%s\n", key, value)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package scripting
+
+import (
+	"strings"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/features"
+)
+
+type StepConflict struct {
+	Step        *features.Step
+	Definitions []StepDefinition
+}
+
+func (c StepConflict) Duplicate() bool {
+	return c.Step == nil
+}
+
+func FindStepConflicts(definitions []StepDefinition, steps []features.Step) []StepConflict {
+	conflicts := []StepConflict{}
+
+	byPattern := map[string][]StepDefinition{}
+	patterns := []string{}
+	for _, definition := range definitions {
+		pattern := stepRegexp(definition.Pattern)
+		if _, ok := byPattern[pattern]; !ok {
+			patterns = append(patterns, pattern)
+		}
+		byPattern[pattern] = append(byPattern[pattern], definition)
+	}
+
+	for _, pattern := range patterns {
+		if len(byPattern[pattern]) > 1 {
+			common.AppLogger.Debug("step pattern %s is registered %d times", pattern, len(byPattern[pattern]))
+			conflicts = append(conflicts, StepConflict{
+				Definitions: byPattern[pattern],
+			})
+		}
+	}
+
+	reported := map[string]bool{}
+	for idx := range steps {
+		matches := MatchingSteps(definitions, steps[idx].Text)
+		if len(matches) < 2 {
+			continue
+		}
+
+		distinct := map[string]bool{}
+		for _, match := range matches {
+			distinct[stepRegexp(match.Pattern)] = true
+		}
+		if len(distinct) < 2 {
+			continue
+		}
+
+		keys := []string{}
+		for _, match := range matches {
+			keys = append(keys, match.Source+":"+match.Pattern)
+		}
+		key := strings.Join(keys, "|")
+		if reported[key] {
+			continue
+		}
+		reported[key] = true
+
+		common.AppLogger.Debug("step %s at %s matches %d definitions", steps[idx].Text, steps[idx].Location(), len(matches))
+		conflicts = append(conflicts, StepConflict{
+			Step:        &steps[idx],
+			Definitions: matches,
+		})
+	}
+
+	return conflicts
+}
+
+func MatchingSteps(definitions []StepDefinition, text string) []StepDefinition {
+	matches := []StepDefinition{}
+	for _, definition := range definitions {
+		expr, err := compileStep(definition.Pattern)
+		if err != nil {
+			common.AppLogger.Warn("could not compile step pattern %s: %s", definition.Pattern, err.Error())
+			continue
+		}
+		if expr.MatchString(text) {
+			matches = append(matches, definition)
+		}
+	}
+
+	return matches
+}
@@ -0,0 +1,61 @@
+package scripting
+
+import (
+	"regexp"
+	"strings"
+)
+
+var expressionParameters = map[string]string{
+	"int":    `(-?\d+)`,
+	"float":  `(-?\d*\.?\d+)`,
+	"word":   `([^\s]+)`,
+	"string": `"([^"]*)"`,
+	"":       `(.*)`,
+}
+
+var expressionParameterPattern = regexp.MustCompile(`(^|[^\\])\{(int|float|word|string|)\}`)
+
+func isExpression(pattern string) bool {
+	if strings.HasPrefix(pattern, "^") || strings.HasSuffix(pattern, "$") {
+		return false
+	}
+	return expressionParameterPattern.MatchString(pattern)
+}
+
+func stepRegexp(pattern string) string {
+	if !isExpression(pattern) {
+		return pattern
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("^")
+	literal := strings.Builder{}
+	flush := func() {
+		sb.WriteString(regexp.QuoteMeta(literal.String()))
+		literal.Reset()
+	}
+
+	for idx := 0; idx < len(pattern); idx++ {
+		char := pattern[idx]
+		if char == '\\' && idx+1 < len(pattern) {
+			idx++
+			literal.WriteByte(pattern[idx])
+			continue
+		}
+		if char == '{' {
+			if end := strings.IndexByte(pattern[idx:], '}'); end >= 0 {
+				if parameter, ok := expressionParameters[pattern[idx+1:idx+end]]; ok {
+					flush()
+					sb.WriteString(parameter)
+					idx += end
+					continue
+				}
+			}
+		}
+		literal.WriteByte(char)
+	}
+	flush()
+	sb.WriteString("$")
+
+	return sb.String()
+}
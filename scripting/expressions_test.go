@@ -0,0 +1,107 @@
+package scripting
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestStepRegexp(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"^I have (\\d+) cukes$", "^I have (\\d+) cukes$"},
+		{"I have (\\d+) cukes", "I have (\\d+) cukes"},
+		{"I have {int} cukes", "^I have (-?\\d+) cukes$"},
+		{"it costs {float}", "^it costs (-?\\d*\\.?\\d+)$"},
+		{"I am {word}", "^I am ([^\\s]+)$"},
+		{"I say {string}", "^I say \"([^\"]*)\"$"},
+		{"anything {}", "^anything (.*)$"},
+		{"{int} + {int} = {int}", "^(-?\\d+) \\+ (-?\\d+) = (-?\\d+)$"},
+		{"what (is) {int}?", "^what \\(is\\) (-?\\d+)\\?$"},
+		{"literal \\{int} and {int}", "^literal \\{int\\} and (-?\\d+)$"},
+		{"unknown {thing} and {int}", "^unknown \\{thing\\} and (-?\\d+)$"},
+		{"^anchored {int}", "^anchored {int}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			if actual := stepRegexp(test.pattern); actual != test.expected {
+				t.Errorf("stepRegexp(%q) = %q, want %q", test.pattern, actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestStepRegexpMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		args    []string
+	}{
+		{"I have {int} cukes", "I have -3 cukes", []string{"-3"}},
+		{"it costs {float}", "it costs 1.50", []string{"1.50"}},
+		{"I say {string} to {word}", `I say "hello there" to bob`, []string{"hello there", "bob"}},
+		{"what (is) {int}?", "what (is) 4?", []string{"4"}},
+		{"I have {int} cukes", "I have many cukes", nil},
+		{"I have {int} cukes", "I have 3 cukes today", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			matches := regexp.MustCompile(stepRegexp(test.pattern)).FindStringSubmatch(test.text)
+			if test.args == nil {
+				if matches != nil {
+					t.Errorf("%q should not match %q", test.pattern, test.text)
+				}
+				return
+			}
+			if matches == nil {
+				t.Fatalf("%q should match %q", test.pattern, test.text)
+			}
+			if len(matches[1:]) != len(test.args) {
+				t.Fatalf("%q matched %q, want %q", test.pattern, matches[1:], test.args)
+			}
+			for idx, arg := range test.args {
+				if matches[idx+1] != arg {
+					t.Errorf("%q argument %d = %q, want %q", test.pattern, idx+1, matches[idx+1], arg)
+				}
+			}
+		})
+	}
+}
+
+func TestSnippetExpression(t *testing.T) {
+	tests := []struct {
+		text       string
+		expression string
+		parameters []string
+	}{
+		{"I have 3 cukes", "I have {int} cukes", []string{"int"}},
+		{`I say "hi" in 1.5 seconds`, "I say {string} in {float} seconds", []string{"string", "float"}},
+		{"what (is) this?", `^what \(is\) this\?$`, []string{}},
+		{"I have 3 (big) cukes?", "I have {int} (big) cukes?", []string{"int"}},
+		{"braces {int} and 2", `braces \{int\} and {int}`, []string{"int"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			expression, parameters := SnippetExpression(test.text)
+			if expression != test.expression {
+				t.Errorf("SnippetExpression(%q) = %q, want %q", test.text, expression, test.expression)
+			}
+			if len(parameters) != len(test.parameters) {
+				t.Fatalf("SnippetExpression(%q) parameters = %q, want %q", test.text, parameters, test.parameters)
+			}
+			for idx, parameter := range test.parameters {
+				if parameters[idx] != parameter {
+					t.Errorf("SnippetExpression(%q) parameter %d = %q, want %q", test.text, idx, parameters[idx], parameter)
+				}
+			}
+
+			if !regexp.MustCompile(stepRegexp(expression)).MatchString(test.text) {
+				t.Errorf("snippet expression %q does not match its own step %q", expression, test.text)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package scripting
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+
+	"github.com/cucumber/godog"
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+
+	"github.com/marmotherder/habitable/common"
+)
+
+type goScript struct {
+	Path        string
+	Package     string
+	Habitable   *Habitable
+	Plugins     map[string]interface{}
+	Interpreter *interp.Interpreter
+}
+
+func (g goScript) getPath() string {
+	return g.Path
+}
+
+func (g *goScript) Load() error {
+	common.AppLogger.Trace("parsing package clause of script file %s", g.Path)
+	file, err := parser.ParseFile(token.NewFileSet(), g.Path, nil, parser.PackageClauseOnly)
+	if err != nil {
+		return err
+	}
+	g.Package = file.Name.Name
+
+	common.AppLogger.Debug("creating go interpreter for %s", g.Path)
+	g.Interpreter = interp.New(interp.Options{})
+	g.Plugins = map[string]interface{}{}
+
+	if err := g.Interpreter.Use(stdlib.Symbols); err != nil {
+		return err
+	}
+	if err := g.Interpreter.Use(godogSymbols); err != nil {
+		return err
+	}
+
+	common.AppLogger.Trace("setting habitable package symbols to interpreter for %s", g.Path)
+	if err := g.Interpreter.Use(interp.Exports{
+		"habitable/habitable": {
+			"Logger":    reflect.ValueOf(&g.Habitable.Logger).Elem(),
+			"Variables": reflect.ValueOf(&g.Habitable.Variables).Elem(),
+			"UsePlugin": reflect.ValueOf(g.Habitable.UsePlugin),
+			"Plugin":    reflect.ValueOf(g.plugin),
+
+			"AddTemplateFunction": reflect.ValueOf(g.Habitable.AddTemplateFunction),
+		},
+	}); err != nil {
+		return err
+	}
+
+	common.AppLogger.Debug("evaluating %s to load plugins", g.Path)
+	if _, err := g.Interpreter.EvalPath(g.Path); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (g *goScript) registerPlugin(name string, plugin interface{}) error {
+	common.AppLogger.Debug("registering plugin %s to %s", name, g.Path)
+	g.Plugins[name] = plugin
+
+	return nil
+}
+
+func (g *goScript) plugin(name string) interface{} {
+	return g.Plugins[name]
+}
+
+func (g *goScript) Run() error {
+	if collectingSteps {
+		return g.collectSteps()
+	}
+
+	if scenarioContext == nil {
+		common.AppLogger.Trace("skipping step initialisation for %s, context not yet loaded", g.Path)
+		return nil
+	}
+
+	common.AppLogger.Trace("looking up InitializeScenario in %s", g.Path)
+	value, err := g.Interpreter.Eval(g.Package + ".InitializeScenario")
+	if err != nil {
+		return err
+	}
+	initializer, ok := value.Interface().(func(*godog.ScenarioContext))
+	if !ok {
+		return fmt.Errorf("InitializeScenario in script %s does not match 'func(*godog.ScenarioContext)'", g.Path)
+	}
+
+	common.AppLogger.Trace("running script %s", g.Path)
+	initializer(scenarioContext)
+	common.AppLogger.Trace("script run finished for %s", g.Path)
+
+	return nil
+}
+
+func (g *goScript) collectSteps() error {
+	common.AppLogger.Trace("parsing %s for step definitions", g.Path)
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, g.Path, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		selector, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || selector.Sel.Name != "Step" {
+			return true
+		}
+		literal, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || literal.Kind != token.STRING {
+			return true
+		}
+		pattern, err := strconv.Unquote(literal.Value)
+		if err != nil {
+			return true
+		}
+
+		line := fileSet.Position(call.Pos()).Line
+		registerStep(StepDefinition{
+			Pattern: pattern,
+			Source:  g.Path,
+			Line:    line,
+			Doc:     docComment(g.Path, line, "//"),
+		})
+		return true
+	})
+
+	return nil
+}
+
+var godogSymbols = interp.Exports{
+	"github.com/cucumber/godog/godog": {
+		"ErrPending":         reflect.ValueOf(&godog.ErrPending).Elem(),
+		"ErrUndefined":       reflect.ValueOf(&godog.ErrUndefined).Elem(),
+		"StepPassed":         reflect.ValueOf(godog.StepPassed),
+		"StepFailed":         reflect.ValueOf(godog.StepFailed),
+		"StepSkipped":        reflect.ValueOf(godog.StepSkipped),
+		"StepUndefined":      reflect.ValueOf(godog.StepUndefined),
+		"StepPending":        reflect.ValueOf(godog.StepPending),
+		"DocString":          reflect.ValueOf((*godog.DocString)(nil)),
+		"Scenario":           reflect.ValueOf((*godog.Scenario)(nil)),
+		"ScenarioContext":    reflect.ValueOf((*godog.ScenarioContext)(nil)),
+		"Step":               reflect.ValueOf((*godog.Step)(nil)),
+		"StepContext":        reflect.ValueOf((*godog.StepContext)(nil)),
+		"StepResultStatus":   reflect.ValueOf((*godog.StepResultStatus)(nil)),
+		"Steps":              reflect.ValueOf((*godog.Steps)(nil)),
+		"Table":              reflect.ValueOf((*godog.Table)(nil)),
+		"AfterScenarioHook":  reflect.ValueOf((*godog.AfterScenarioHook)(nil)),
+		"AfterStepHook":      reflect.ValueOf((*godog.AfterStepHook)(nil)),
+		"BeforeScenarioHook": reflect.ValueOf((*godog.BeforeScenarioHook)(nil)),
+		"BeforeStepHook":     reflect.ValueOf((*godog.BeforeStepHook)(nil)),
+	},
+}
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/cucumber/godog"
 	"github.com/dop251/goja"
@@ -16,8 +18,12 @@ import (
 	"github.com/marmotherder/habitable/hashes"
 )
 
+var javascriptSourceDirs []string
+
 func generateJavascriptScripts(scriptDirs []string) error {
-	changes, err := hashes.CheckDirectoryHashes(scriptDirs...)
+	javascriptSourceDirs = scriptDirs
+
+	changes, err := hashes.DirectoryHashesChanged(scriptDirs...)
 	if err != nil {
 		return err
 	}
@@ -26,9 +32,26 @@ func generateJavascriptScripts(scriptDirs []string) error {
 	}
 
 	for _, scriptDir := range scriptDirs {
+		packageJson, err := os.ReadFile(filepath.Join(scriptDir, "package.json"))
+		if err != nil {
+			common.AppLogger.Debug("no package.json found in %s, skipping npm install", scriptDir)
+			continue
+		}
+		packageFile := filepath.Join(scriptDir, "package.json")
+		packageChanges, err := hashes.StringHashChanged(packageFile, string(packageJson))
+		if err != nil {
+			return err
+		}
+		if !packageChanges && copy.Exists(filepath.Join(scriptDir, "node_modules")) {
+			common.AppLogger.Debug("dependencies in %s are unchanged, skipping npm install", scriptDir)
+			continue
+		}
 		if _, _, err := command.RunCommand(scriptDir, "npm", "i"); err != nil {
 			return err
 		}
+		if err := hashes.SaveStringHash(packageFile, string(packageJson)); err != nil {
+			return err
+		}
 	}
 
 	common.AppLogger.Info("scripts in defined folders have changed, creating a javascript build environment")
@@ -82,7 +105,7 @@ func generateJavascriptScripts(scriptDirs []string) error {
 		return err
 	}
 
-	return nil
+	return hashes.SaveDirectoryHashes(scriptDirs...)
 }
 
 const babel = `'use strict'
@@ -194,7 +217,9 @@ module.exports = {
   output: {
     path: path.resolve(__dirname, '../../scripts'),
     filename: 'scripts.js',
+    devtoolModuleFilenameTemplate: '[absolute-resource-path]',
   },
+  devtool: 'source-map',
   devServer: {
     contentBase: path.resolve(__dirname, '../'),
   },
@@ -225,7 +250,19 @@ func (j *javascriptScript) Load() error {
 	if err != nil {
 		return err
 	}
+	j.Script = string(script)
+
+	if err := j.setup(); err != nil {
+		return err
+	}
 
+	common.AppLogger.Debug("executing %s to load plugins", j.Path)
+	j.Run()
+
+	return nil
+}
+
+func (j *javascriptScript) setup() error {
 	common.AppLogger.Debug("creating javascript vm for %s", j.Path)
 	vm := goja.New()
 
@@ -235,20 +272,14 @@ func (j *javascriptScript) Load() error {
 	vm.SetFieldNameMapper(goja.UncapFieldNameMapper())
 
 	j.Runtime = vm
-	j.Script = string(script)
 
-	j.Habitable.AddStep = j.AddStep
+	habitable := *j.Habitable
+	habitable.AddStep = j.AddStep
+	habitable.AddTemplateFunction = j.AddTemplateFunction
 
 	common.AppLogger.Trace("setting global object habitable to vm for %s", j.Path)
-	common.AppLogger.Debug(j.Habitable)
-	if err := vm.Set("habitable", j.Habitable); err != nil {
-		return err
-	}
-
-	common.AppLogger.Debug("executing %s to load plugins", j.Path)
-	j.Run()
-
-	return nil
+	common.AppLogger.Debug(habitable)
+	return vm.Set("habitable", &habitable)
 }
 
 func (j *javascriptScript) registerPlugin(name string, plugin interface{}) error {
@@ -262,7 +293,7 @@ func (j *javascriptScript) registerPlugin(name string, plugin interface{}) error
 
 func (j *javascriptScript) Run() error {
 	common.AppLogger.Trace("running script %s", j.Path)
-	if _, err := j.Runtime.RunString(j.Script); err != nil {
+	if _, err := j.Runtime.RunScript(j.Path, j.Script); err != nil {
 		return err
 	}
 	common.AppLogger.Trace("script run finished for %s", j.Path)
@@ -271,44 +302,123 @@ func (j *javascriptScript) Run() error {
 }
 
 func (j javascriptScript) AddStep(step string, function interface{}) {
-	if scenarioContext == nil {
-		common.AppLogger.Trace("skipping adding step %s for %s, context not yet loaded", step, j.Path)
-		return
+	definition := StepDefinition{
+		Pattern: step,
+		Source:  j.Path,
+	}
+	for _, frame := range j.Runtime.CaptureCallStack(0, nil) {
+		if position := frame.Position(); position.Line > 0 {
+			definition.Source = javascriptSource(position.Filename)
+			definition.Line = position.Line
+			definition.Doc = docComment(definition.Source, definition.Line, "//", "*", "/**")
+			break
+		}
 	}
-	common.AppLogger.Debug("adding step %s for %s", step, j.Path)
-	scenarioContext.Step(step, func(arg string) error {
+
+	definition.Pending = function == nil
+
+	handler, err := newStepHandler(step, func(args []string, argument interface{}) error {
+		if definition.Pending {
+			return godog.ErrPending
+		}
+
 		functionValue := j.Runtime.ToValue(function)
 		callable, isCallable := goja.AssertFunction(functionValue)
 		if !isCallable {
 			return fmt.Errorf("function %s in script %s is not callable", function, j.Path)
 		}
 
-		var value goja.Value
-		if intValue, err := strconv.Atoi(arg); err == nil {
-			value = j.Runtime.ToValue(intValue)
-		} else if boolValue, err := strconv.ParseBool(arg); err == nil {
-			value = j.Runtime.ToValue(boolValue)
-		} else {
-			value = j.Runtime.ToValue(arg)
+		values := make([]goja.Value, len(args))
+		for idx, arg := range args {
+			values[idx] = j.Runtime.ToValue(stepArgument(arg))
+		}
+		if argument != nil {
+			values = append(values, j.Runtime.ToValue(argument))
 		}
 
-		resp, err := callable(goja.Undefined(), value)
+		resp, err := callable(goja.Undefined(), values...)
 		if err != nil {
 			return err
 		}
 
-		if !resp.SameAs(goja.Undefined()) {
+		if !resp.SameAs(goja.Undefined()) && !resp.SameAs(goja.Null()) {
 			respObj := resp.ToObject(j.Runtime)
 			switch respObj.ClassName() {
 			case "Error":
 				return errors.New(respObj.String())
 			case "Promise":
 				if prom, ok := resp.Export().(*goja.Promise); ok {
-					prom.Result()
+					if prom.State() == goja.PromiseStateRejected {
+						return errors.New(prom.Result().String())
+					}
+					if result := prom.Result(); result != nil && !goja.IsUndefined(result) && !goja.IsNull(result) {
+						respObj = result.ToObject(j.Runtime)
+					}
+				}
+			}
+
+			if vars := respObj.Get("vars"); vars != nil && !goja.IsUndefined(vars) && !goja.IsNull(vars) {
+				varsMap, ok := vars.Export().(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("step returned vars as %s, it must be an object of variable names to values", vars.ExportType())
+				}
+				if err := applyStepVariables(j.Habitable, varsMap); err != nil {
+					return err
 				}
 			}
 		}
 
 		return nil
 	})
+	if err != nil {
+		common.AppLogger.Error("failed to add step %s for %s: %s", step, j.Path, err.Error())
+		return
+	}
+	definition.Handler = handler
+
+	registerStep(definition)
+}
+
+func (j javascriptScript) AddTemplateFunction(name string, function interface{}) {
+	callable, isCallable := goja.AssertFunction(j.Runtime.ToValue(function))
+	if !isCallable {
+		common.AppLogger.Error("template function %s in script %s is not callable", name, j.Path)
+		return
+	}
+
+	registerTemplateFunction(name, func(args ...interface{}) (interface{}, error) {
+		values := make([]goja.Value, len(args))
+		for idx, arg := range args {
+			values[idx] = j.Runtime.ToValue(arg)
+		}
+
+		resp, err := callable(goja.Undefined(), values...)
+		if err != nil {
+			return nil, templateFunctionError(name, err)
+		}
+		return resp.Export(), nil
+	})
+}
+
+func javascriptSource(filename string) string {
+	buildDir, err := filepath.Abs(common.TempBuildDir() + "/" + "javascript")
+	if err != nil {
+		return filename
+	}
+
+	relative, err := filepath.Rel(buildDir, filename)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		return filename
+	}
+
+	parts := strings.SplitN(filepath.ToSlash(relative), "/", 2)
+	if len(parts) != 2 {
+		return filename
+	}
+	idx, err := strconv.Atoi(parts[0])
+	if err != nil || idx >= len(javascriptSourceDirs) {
+		return filename
+	}
+
+	return filepath.Join(javascriptSourceDirs[idx], parts[1])
 }
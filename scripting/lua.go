@@ -0,0 +1,367 @@
+package scripting
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cucumber/godog"
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/marmotherder/habitable/common"
+)
+
+type luaScript struct {
+	Path      string
+	Script    string
+	Habitable *Habitable
+	State     *lua.LState
+}
+
+func (l luaScript) getPath() string {
+	return l.Path
+}
+
+func (l *luaScript) Load() error {
+	common.AppLogger.Trace("opening script file %s", l.Path)
+	script, err := os.ReadFile(l.Path)
+	if err != nil {
+		return err
+	}
+
+	common.AppLogger.Debug("creating lua vm for %s", l.Path)
+	if l.State != nil {
+		l.State.Close()
+	}
+	l.State = lua.NewState()
+	l.Script = string(script)
+
+	habitable := *l.Habitable
+	habitable.AddStep = l.AddStep
+	habitable.AddTemplateFunction = l.AddTemplateFunction
+
+	common.AppLogger.Trace("setting global object habitable to vm for %s", l.Path)
+	l.State.SetGlobal("habitable", luaValue(l.State, &habitable))
+
+	common.AppLogger.Debug("executing %s to load plugins", l.Path)
+	return l.Run()
+}
+
+func (l *luaScript) registerPlugin(name string, plugin interface{}) error {
+	common.AppLogger.Debug("registering plugin %s to %s", name, l.Path)
+	l.State.SetGlobal(name, luaValue(l.State, plugin))
+
+	return nil
+}
+
+func (l *luaScript) Run() error {
+	common.AppLogger.Trace("running script %s", l.Path)
+	chunk, err := l.State.Load(strings.NewReader(l.Script), l.Path)
+	if err != nil {
+		return err
+	}
+	l.State.Push(chunk)
+	if err := l.State.PCall(0, lua.MultRet, nil); err != nil {
+		return err
+	}
+	common.AppLogger.Trace("script run finished for %s", l.Path)
+
+	return nil
+}
+
+func (l *luaScript) AddStep(step string, function interface{}) {
+	definition := StepDefinition{
+		Pattern: step,
+		Source:  l.Path,
+	}
+	if where := strings.Split(l.State.Where(1), ":"); len(where) > 1 {
+		definition.Line, _ = strconv.Atoi(where[len(where)-2])
+		definition.Doc = docComment(l.Path, definition.Line, "--")
+	}
+
+	definition.Pending = function == nil
+
+	handler, err := newStepHandler(step, func(args []string, argument interface{}) error {
+		if definition.Pending {
+			return godog.ErrPending
+		}
+
+		callable, ok := function.(*lua.LFunction)
+		if !ok {
+			return fmt.Errorf("function %s in script %s is not callable", function, l.Path)
+		}
+
+		values := make([]lua.LValue, len(args))
+		for idx, arg := range args {
+			values[idx] = luaValue(l.State, stepArgument(arg))
+		}
+		if argument != nil {
+			values = append(values, luaValue(l.State, argument))
+		}
+
+		if err := l.State.CallByParam(lua.P{
+			Fn:      callable,
+			NRet:    2,
+			Protect: true,
+		}, values...); err != nil {
+			if apiErr, ok := err.(*lua.ApiError); ok {
+				return errors.New(apiErr.Object.String())
+			}
+			return err
+		}
+		result := l.State.Get(-2)
+		failure := l.State.Get(-1)
+		l.State.Pop(2)
+
+		if failure != lua.LNil {
+			return errors.New(failure.String())
+		}
+
+		if table, ok := result.(*lua.LTable); ok {
+			switch vars := table.RawGetString("vars").(type) {
+			case *lua.LNilType:
+			case *lua.LTable:
+				if vars.MaxN() > 0 {
+					return errors.New("step returned vars as a list, it must be a table of variable names to values")
+				}
+				varsValue, err := goValue(vars, reflect.TypeOf(map[string]interface{}{}))
+				if err != nil {
+					return err
+				}
+				if err := applyStepVariables(l.Habitable, varsValue.Interface().(map[string]interface{})); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("step returned vars as a %s, it must be a table of variable names to values", vars.Type().String())
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		common.AppLogger.Error("failed to add step %s for %s: %s", step, l.Path, err.Error())
+		return
+	}
+	definition.Handler = handler
+
+	registerStep(definition)
+}
+
+func (l *luaScript) AddTemplateFunction(name string, function interface{}) {
+	fn, ok := function.(*lua.LFunction)
+	if !ok {
+		common.AppLogger.Error("template function %s in script %s is not a function", name, l.Path)
+		return
+	}
+
+	registerTemplateFunction(name, func(args ...interface{}) (interface{}, error) {
+		values := make([]lua.LValue, len(args))
+		for idx, arg := range args {
+			values[idx] = luaValue(l.State, arg)
+		}
+
+		if err := l.State.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}, values...); err != nil {
+			return nil, templateFunctionError(name, err)
+		}
+		result := l.State.Get(-1)
+		l.State.Pop(1)
+
+		value, err := goValue(result, reflect.TypeOf((*interface{})(nil)).Elem())
+		if err != nil {
+			return nil, templateFunctionError(name, err)
+		}
+		return value.Interface(), nil
+	})
+}
+
+func luaValue(L *lua.LState, value interface{}) lua.LValue {
+	switch v := value.(type) {
+	case nil:
+		return lua.LNil
+	case lua.LValue:
+		return v
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Type().NumMethod() == 0 {
+		switch rv.Kind() {
+		case reflect.Bool:
+			return lua.LBool(rv.Bool())
+		case reflect.String:
+			return lua.LString(rv.String())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return lua.LNumber(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return lua.LNumber(rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			return lua.LNumber(rv.Float())
+		case reflect.Slice, reflect.Array:
+			table := L.NewTable()
+			for i := 0; i < rv.Len(); i++ {
+				table.Append(luaValue(L, rv.Index(i).Interface()))
+			}
+			return table
+		case reflect.Map:
+			table := L.NewTable()
+			for _, key := range rv.MapKeys() {
+				table.RawSetString(fmt.Sprint(key.Interface()), luaValue(L, rv.MapIndex(key).Interface()))
+			}
+			return table
+		case reflect.Func:
+			return L.NewFunction(luaFunction(rv))
+		}
+	}
+
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return lua.LNil
+	}
+
+	ud := L.NewUserData()
+	ud.Value = value
+	meta := L.NewTable()
+	meta.RawSetString("__index", L.NewFunction(func(L *lua.LState) int {
+		L.Push(luaIndex(L, L.CheckUserData(1), L.CheckString(2)))
+		return 1
+	}))
+	L.SetMetatable(ud, meta)
+
+	return ud
+}
+
+func luaIndex(L *lua.LState, ud *lua.LUserData, key string) lua.LValue {
+	names := []string{key}
+	if key != "" {
+		runes := []rune(key)
+		runes[0] = unicode.ToUpper(runes[0])
+		names = append(names, string(runes))
+	}
+
+	rv := reflect.ValueOf(ud.Value)
+	for _, name := range names {
+		if method := rv.MethodByName(name); method.IsValid() {
+			return L.NewFunction(luaMethod(ud, method))
+		}
+	}
+
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Struct {
+		for _, name := range names {
+			if field, ok := rv.Type().FieldByName(name); ok && field.PkgPath == "" {
+				return luaValue(L, rv.FieldByIndex(field.Index).Interface())
+			}
+		}
+	}
+
+	return lua.LNil
+}
+
+func luaMethod(ud *lua.LUserData, method reflect.Value) lua.LGFunction {
+	call := luaFunction(method)
+	return func(L *lua.LState) int {
+		if L.GetTop() > 0 && L.Get(1) == ud {
+			L.Remove(1)
+		}
+		return call(L)
+	}
+}
+
+func luaFunction(fn reflect.Value) lua.LGFunction {
+	return func(L *lua.LState) int {
+		fnType := fn.Type()
+		top := L.GetTop()
+
+		args := []reflect.Value{}
+		for i := 0; i < top; i++ {
+			var argType reflect.Type
+			switch {
+			case fnType.IsVariadic() && i >= fnType.NumIn()-1:
+				argType = fnType.In(fnType.NumIn() - 1).Elem()
+			case i < fnType.NumIn():
+				argType = fnType.In(i)
+			default:
+				continue
+			}
+
+			arg, err := goValue(L.Get(i+1), argType)
+			if err != nil {
+				L.RaiseError("argument %d: %s", i+1, err.Error())
+				return 0
+			}
+			args = append(args, arg)
+		}
+		for len(args) < fnType.NumIn() && !(fnType.IsVariadic() && len(args) == fnType.NumIn()-1) {
+			args = append(args, reflect.Zero(fnType.In(len(args))))
+		}
+
+		results := fn.Call(args)
+		if len(results) > 0 && fnType.Out(len(results)-1) == errorType {
+			if err, _ := results[len(results)-1].Interface().(error); err != nil {
+				L.RaiseError(err.Error())
+				return 0
+			}
+			results = results[:len(results)-1]
+		}
+
+		for _, result := range results {
+			L.Push(luaValue(L, result.Interface()))
+		}
+		return len(results)
+	}
+}
+
+func goValue(value lua.LValue, target reflect.Type) (reflect.Value, error) {
+	var converted interface{}
+	switch v := value.(type) {
+	case *lua.LNilType:
+		return reflect.Zero(target), nil
+	case lua.LBool:
+		converted = bool(v)
+	case lua.LString:
+		converted = string(v)
+	case lua.LNumber:
+		if float64(v) == float64(int64(v)) {
+			converted = int(v)
+		} else {
+			converted = float64(v)
+		}
+	case *lua.LTable:
+		if v.MaxN() > 0 {
+			list := []interface{}{}
+			v.ForEach(func(_, item lua.LValue) {
+				itemValue, _ := goValue(item, reflect.TypeOf((*interface{})(nil)).Elem())
+				list = append(list, itemValue.Interface())
+			})
+			converted = list
+		} else {
+			table := map[string]interface{}{}
+			v.ForEach(func(key, item lua.LValue) {
+				itemValue, _ := goValue(item, reflect.TypeOf((*interface{})(nil)).Elem())
+				table[key.String()] = itemValue.Interface()
+			})
+			converted = table
+		}
+	case *lua.LUserData:
+		converted = v.Value
+	default:
+		converted = v
+	}
+
+	rv := reflect.ValueOf(converted)
+	if rv.Type().AssignableTo(target) {
+		return rv, nil
+	}
+	if rv.Type().ConvertibleTo(target) && rv.Kind() != reflect.String && target.Kind() != reflect.String {
+		return rv.Convert(target), nil
+	}
+	if target.Kind() == reflect.String {
+		return reflect.ValueOf(fmt.Sprint(converted)).Convert(target), nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot use %s as %s", value.Type().String(), target)
+}
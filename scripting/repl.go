@@ -0,0 +1,221 @@
+package scripting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/cucumber/godog"
+	"github.com/dop251/goja"
+
+	"github.com/marmotherder/habitable/common"
+)
+
+const replSource = "repl"
+
+type Repl struct {
+	script *javascriptScript
+	steps  []StepDefinition
+}
+
+type StepResult struct {
+	Definition StepDefinition
+	Args       []string
+	Err        error
+}
+
+func NewRepl() (*Repl, error) {
+	steps, err := CollectSteps()
+	if err != nil {
+		return nil, err
+	}
+
+	script := replRuntime()
+	if script == nil {
+		common.AppLogger.Debug("no javascript scripts loaded, creating a new runtime for the repl")
+		script = &javascriptScript{
+			Path:      replSource,
+			Habitable: newHabitable(),
+		}
+		if err := script.setup(); err != nil {
+			return nil, err
+		}
+		for name, plugin := range loadedPlugins {
+			common.AppLogger.Debug("loading plugin %s to repl", name)
+			if err := script.registerPlugin(name, plugin); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &Repl{
+		script: script,
+		steps:  steps,
+	}, nil
+}
+
+func replRuntime() *javascriptScript {
+	for _, script := range scripts {
+		if j, ok := script.(*javascriptScript); ok && j.Runtime != nil {
+			common.AppLogger.Debug("evaluating repl input in the runtime of %s", j.Path)
+			return j
+		}
+	}
+	return nil
+}
+
+func (r *Repl) Steps() []StepDefinition {
+	return r.steps
+}
+
+func (r *Repl) Eval(source string) (string, error) {
+	collectingSteps = true
+	collectedSteps = []StepDefinition{}
+	defer func() {
+		collectingSteps = false
+		for _, step := range collectedSteps {
+			if step.Params == nil {
+				step.Params = stepParams(stepRegexp(step.Pattern))
+			}
+			r.steps = append(r.steps, step)
+		}
+	}()
+
+	value, err := r.script.Runtime.RunScript(replSource, source)
+	if err != nil {
+		return "", err
+	}
+	if value == nil || goja.IsUndefined(value) {
+		return "", nil
+	}
+
+	return value.String(), nil
+}
+
+func (r *Repl) RunStep(text string) ([]StepDefinition, *StepResult) {
+	matches := MatchingSteps(r.steps, text)
+	if len(matches) != 1 {
+		return matches, nil
+	}
+
+	result := &StepResult{Definition: matches[0]}
+	expr, err := compileStep(result.Definition.Pattern)
+	if err != nil {
+		result.Err = err
+		return matches, result
+	}
+	result.Args = expr.FindStringSubmatch(text)[1:]
+
+	if result.Definition.Handler == nil {
+		result.Err = fmt.Errorf("step %s from %s cannot be run outside of a test run", result.Definition.Pattern, result.Definition.Source)
+		return matches, result
+	}
+
+	result.Err = callStepHandler(result.Definition.Handler, result.Args)
+
+	return matches, result
+}
+
+var (
+	contextType   = reflect.TypeOf((*context.Context)(nil)).Elem()
+	docStringType = reflect.TypeOf((*godog.DocString)(nil))
+	tableType     = reflect.TypeOf((*godog.Table)(nil))
+)
+
+// callStepHandler runs a step handler outside of godog, converting the
+// matched arguments the same way godog does for native go steps
+func callStepHandler(handler interface{}, args []string) (err error) {
+	value := reflect.ValueOf(handler)
+	if value.Kind() != reflect.Func {
+		return fmt.Errorf("step handler is %T, not a function", handler)
+	}
+	handlerType := value.Type()
+	if handlerType.IsVariadic() {
+		return errors.New("variadic step handlers are not supported")
+	}
+
+	values := make([]reflect.Value, handlerType.NumIn())
+	argIdx := 0
+	for idx := range values {
+		paramType := handlerType.In(idx)
+		switch {
+		case paramType == contextType:
+			values[idx] = reflect.ValueOf(context.Background())
+			continue
+		case paramType == docStringType || paramType == tableType:
+			values[idx] = reflect.Zero(paramType)
+			continue
+		}
+
+		if argIdx >= len(args) {
+			return fmt.Errorf("step handler expects more arguments than the %d matched", len(args))
+		}
+		converted, err := convertStepArgument(args[argIdx], paramType)
+		if err != nil {
+			return fmt.Errorf("argument %d: %s", argIdx+1, err.Error())
+		}
+		values[idx] = converted
+		argIdx++
+	}
+	if argIdx != len(args) {
+		return fmt.Errorf("step handler takes %d arguments but %d were matched", argIdx, len(args))
+	}
+	if handlerType.NumOut() > 2 {
+		return fmt.Errorf("step handler returns %d values, expected at most 2", handlerType.NumOut())
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("step panicked: %v", r)
+		}
+	}()
+
+	for _, out := range value.Call(values) {
+		if outErr, ok := out.Interface().(error); ok && outErr != nil {
+			return outErr
+		}
+	}
+	return nil
+}
+
+func convertStepArgument(arg string, paramType reflect.Type) (reflect.Value, error) {
+	value := reflect.New(paramType).Elem()
+	switch paramType.Kind() {
+	case reflect.String:
+		value.SetString(arg)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(arg, 10, paramType.Bits())
+		if err != nil {
+			return value, err
+		}
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(arg, 10, paramType.Bits())
+		if err != nil {
+			return value, err
+		}
+		value.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(arg, paramType.Bits())
+		if err != nil {
+			return value, err
+		}
+		value.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(arg)
+		if err != nil {
+			return value, err
+		}
+		value.SetBool(parsed)
+	case reflect.Slice:
+		if paramType.Elem().Kind() != reflect.Uint8 {
+			return value, fmt.Errorf("unsupported argument type %s", paramType)
+		}
+		value.SetBytes([]byte(arg))
+	default:
+		return value, fmt.Errorf("unsupported argument type %s", paramType)
+	}
+	return value, nil
+}
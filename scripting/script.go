@@ -4,18 +4,23 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/cucumber/godog"
 	"github.com/marmotherder/habitable/common"
 	"github.com/marmotherder/habitable/logger"
 	"github.com/marmotherder/habitable/plugins"
+	"github.com/marmotherder/habitable/plugins/stepplugin"
 )
 
 type Habitable struct {
-	Logger    logger.Logger
-	Variables common.HabitableVariables
-	UsePlugin func(name string, version string, location ...string)
-	AddStep   func(step string, function interface{})
+	Logger              logger.Logger
+	Variables           *common.HabitableVariables
+	UsePlugin           func(name string, version string, options ...interface{})
+	AddStep             func(step string, function interface{})
+	AddTemplateFunction func(name string, function interface{})
 }
 
 type Script interface {
@@ -25,10 +30,14 @@ type Script interface {
 	Run() error
 }
 
-var scripts map[string]Script
+var (
+	scripts       map[string]Script
+	loadedPlugins map[string]interface{}
+)
 
 func LoadScripts(dirs ...string) error {
 	javascriptDirs := []string{}
+	sourceFiles := []string{}
 	common.AppLogger.Debug("attempting to load scripts from %s", dirs)
 	for _, scriptDir := range dirs {
 		contents, err := os.ReadDir(scriptDir)
@@ -39,14 +48,25 @@ func LoadScripts(dirs ...string) error {
 		common.AppLogger.Debug("read directory %s for scripts", scriptDir)
 
 		valid := false
+		hasJavascript := false
 		for _, content := range contents {
 			if !content.IsDir() {
 				ext := filepath.Ext(content.Name())
-				if ext == ".js" {
+				switch ext {
+				case ".js":
+					valid = true
+					if !hasJavascript {
+						hasJavascript = true
+						javascriptDirs = append(javascriptDirs, scriptDir)
+						common.AppLogger.Debug("directory %s has scripts for javascript, adding to loader", scriptDir)
+					}
+				case ".lua", ".go", ".sh":
+					if strings.HasSuffix(content.Name(), "_test.go") {
+						continue
+					}
 					valid = true
-					javascriptDirs = append(javascriptDirs, scriptDir)
-					common.AppLogger.Debug("directory %s has scripts for javascript, adding to loader", scriptDir)
-					break
+					sourceFiles = append(sourceFiles, filepath.Join(scriptDir, content.Name()))
+					common.AppLogger.Debug("directory %s has %s script %s, adding to loader", scriptDir, ext, content.Name())
 				}
 			}
 		}
@@ -56,16 +76,14 @@ func LoadScripts(dirs ...string) error {
 		}
 	}
 
-	if err := generateJavascriptScripts(javascriptDirs); err != nil {
-		common.AppLogger.Fatal(common.SetupError, err.Error())
+	if len(javascriptDirs) > 0 {
+		if err := generateJavascriptScripts(javascriptDirs); err != nil {
+			common.AppLogger.Fatal(common.SetupError, err.Error())
+		}
 	}
 
 	common.AppLogger.Trace("setting up script global object")
-	habitable := &Habitable{
-		Logger:    common.AppLogger,
-		Variables: common.Variables,
-		UsePlugin: plugins.UsePlugin,
-	}
+	habitable := newHabitable()
 	common.AppLogger.Trace(habitable)
 
 	common.AppLogger.Trace("load process scripts in %s", common.TempScriptsDir())
@@ -85,12 +103,36 @@ func LoadScripts(dirs ...string) error {
 					Path:      fmt.Sprintf("%s/%s", common.TempScriptsDir(), content.Name()),
 					Habitable: habitable,
 				}
+			case ".map":
+				common.AppLogger.Trace("skipping source map %s", content.Name())
 			default:
 				common.AppLogger.Error("no supported file extension found for extension file: %s", content.Name())
 			}
 		}
 	}
 
+	for _, sourceFile := range sourceFiles {
+		common.AppLogger.Debug("adding script %s to loader", sourceFile)
+		switch filepath.Ext(sourceFile) {
+		case ".lua":
+			scripts[sourceFile] = &luaScript{
+				Path:      sourceFile,
+				Habitable: habitable,
+			}
+		case ".go":
+			scripts[sourceFile] = &goScript{
+				Path:      sourceFile,
+				Habitable: habitable,
+			}
+		case ".sh":
+			scripts[sourceFile] = &shellScript{
+				Path:      sourceFile,
+				Habitable: habitable,
+			}
+		}
+	}
+
+	defer common.Variables.SetDefaultScope(common.Variables.SetDefaultScope(common.SuiteScope))
 	for name, script := range scripts {
 		common.AppLogger.Debug("loading %s", name)
 		if err := script.Load(); err != nil {
@@ -100,7 +142,7 @@ func LoadScripts(dirs ...string) error {
 	}
 
 	common.AppLogger.Info("resolving plugins found defined in script files")
-	loadedPlugins, err := plugins.ResolvePlugins()
+	loadedPlugins, err = plugins.ResolvePlugins()
 	if err != nil {
 		return err
 	}
@@ -118,10 +160,21 @@ func LoadScripts(dirs ...string) error {
 	return nil
 }
 
+func newHabitable() *Habitable {
+	return &Habitable{
+		Logger:    common.AppLogger,
+		Variables: common.Variables,
+		UsePlugin: plugins.UsePlugin,
+
+		AddTemplateFunction: registerTemplateFunction,
+	}
+}
+
 var scenarioContext *godog.ScenarioContext
 
 func RegisterSteps(ctx *godog.ScenarioContext) error {
 	scenarioContext = ctx
+	defer common.Variables.SetDefaultScope(common.Variables.SetDefaultScope(common.SuiteScope))
 
 	for name, script := range scripts {
 		common.AppLogger.Debug("running %s to register defined steps", name)
@@ -131,5 +184,24 @@ func RegisterSteps(ctx *godog.ScenarioContext) error {
 		}
 	}
 
+	for name, stepPlugin := range plugins.StepPlugins {
+		if stepPlugin.InitializeScenario != nil {
+			common.AppLogger.Debug("running plugin %s to register go steps", name)
+			stepPlugin.InitializeScenario(stepplugin.NewScenarioContext(ctx))
+		}
+	}
+
 	return nil
 }
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func stepArgument(arg string) interface{} {
+	if intValue, err := strconv.Atoi(arg); err == nil {
+		return intValue
+	}
+	if boolValue, err := strconv.ParseBool(arg); err == nil {
+		return boolValue
+	}
+	return arg
+}
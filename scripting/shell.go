@@ -0,0 +1,191 @@
+package scripting
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/marmotherder/habitable/command"
+	"github.com/marmotherder/habitable/common"
+)
+
+const (
+	shellStepAnnotation = "step:"
+	shellOutputVariable = "HABITABLE_OUTPUT"
+	shellStepVariable   = "HABITABLE_STEP"
+	shellArgPrefix      = "HABITABLE_ARG_"
+)
+
+type shellScript struct {
+	Path      string
+	Steps     []StepDefinition
+	Habitable *Habitable
+}
+
+func (s shellScript) getPath() string {
+	return s.Path
+}
+
+func (s *shellScript) Load() error {
+	common.AppLogger.Trace("opening script file %s", s.Path)
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	s.Steps = []StepDefinition{}
+	doc := []string{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#!") || line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+
+		annotation := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		if strings.HasPrefix(annotation, shellStepAnnotation) {
+			step := strings.TrimSpace(strings.TrimPrefix(annotation, shellStepAnnotation))
+			common.AppLogger.Debug("found step annotation %s in %s", step, s.Path)
+			s.Steps = append(s.Steps, StepDefinition{
+				Pattern: step,
+				Source:  s.Path,
+				Line:    lineNumber,
+			})
+		} else {
+			doc = append(doc, annotation)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	for idx := range s.Steps {
+		s.Steps[idx].Doc = strings.TrimSpace(strings.Join(doc, "\n"))
+	}
+
+	if len(s.Steps) == 0 {
+		common.AppLogger.Warn("script %s has no '# %s' annotation in its header, no steps will be registered", s.Path, shellStepAnnotation)
+	}
+
+	return nil
+}
+
+func (s *shellScript) registerPlugin(name string, plugin interface{}) error {
+	common.AppLogger.Debug("plugin %s is not available to shell script %s, skipping", name, s.Path)
+
+	return nil
+}
+
+func (s *shellScript) Run() error {
+	for _, step := range s.Steps {
+		if err := s.AddStep(step); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *shellScript) AddStep(definition StepDefinition) error {
+	handler, err := newStepHandler(definition.Pattern, func(args []string, argument interface{}) error {
+		switch value := argument.(type) {
+		case string:
+			args = append(args, value)
+		case [][]string:
+			table, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			args = append(args, string(table))
+		}
+		return s.execute(definition.Pattern, args)
+	})
+	if err != nil {
+		return err
+	}
+	definition.Handler = handler
+
+	registerStep(definition)
+
+	return nil
+}
+
+func (s *shellScript) execute(step string, args []string) error {
+	output, err := os.CreateTemp(common.TempBuildDir(), "shell-*.env")
+	if err != nil {
+		return err
+	}
+	output.Close()
+	defer os.Remove(output.Name())
+
+	outputPath, err := filepath.Abs(output.Name())
+	if err != nil {
+		return err
+	}
+	scriptPath, err := filepath.Abs(s.Path)
+	if err != nil {
+		return err
+	}
+
+	env := []string{}
+	for key, value := range s.Habitable.Variables.Map() {
+		env = append(env, key+"="+value)
+	}
+	env = append(env, shellStepVariable+"="+step, shellOutputVariable+"="+outputPath)
+	for idx, arg := range args {
+		env = append(env, fmt.Sprintf("%s%d=%s", shellArgPrefix, idx+1, arg))
+	}
+
+	name, cmdArgs := scriptPath, args
+	if info, err := os.Stat(scriptPath); err == nil && info.Mode()&0111 == 0 {
+		common.AppLogger.Trace("script %s is not executable, running through sh", s.Path)
+		name, cmdArgs = "sh", append([]string{scriptPath}, args...)
+	}
+
+	_, stdErr, runErr := command.RunCommandWithEnv(".", env, name, cmdArgs...)
+
+	if err := s.importVariables(outputPath); err != nil {
+		return err
+	}
+
+	if runErr != nil {
+		if stdErr = strings.TrimSpace(stdErr); stdErr != "" {
+			return errors.New(common.Redact(stdErr))
+		}
+		return fmt.Errorf("script %s failed: %s", s.Path, runErr.Error())
+	}
+
+	return nil
+}
+
+func (s *shellScript) importVariables(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, err := common.ParseKeyValue(line)
+		if err != nil {
+			common.AppLogger.Warn("ignoring malformed variable line from %s: %s", s.Path, line)
+			continue
+		}
+		s.Habitable.Variables.Set(key, value)
+	}
+
+	return scanner.Err()
+}
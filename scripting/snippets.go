@@ -0,0 +1,59 @@
+package scripting
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var snippetParameterPattern = regexp.MustCompile(`"[^"]*"|-?\b\d+\.\d+\b|-?\b\d+\b`)
+
+var snippetTypes = map[string]string{
+	"int":    "number",
+	"float":  "number",
+	"string": "string",
+}
+
+func SnippetExpression(text string) (string, []string) {
+	if !snippetParameterPattern.MatchString(text) {
+		return "^" + regexp.QuoteMeta(text) + "$", []string{}
+	}
+
+	escaped := strings.NewReplacer(`\`, `\\`, "{", `\{`, "}", `\}`).Replace(text)
+
+	parameters := []string{}
+	expression := snippetParameterPattern.ReplaceAllStringFunc(escaped, func(match string) string {
+		parameter := "int"
+		switch {
+		case strings.HasPrefix(match, `"`):
+			parameter = "string"
+		case strings.Contains(match, "."):
+			parameter = "float"
+		}
+		parameters = append(parameters, parameter)
+		return "{" + parameter + "}"
+	})
+
+	return expression, parameters
+}
+
+func Snippet(text, language string) string {
+	expression, parameters := SnippetExpression(text)
+
+	counts := map[string]int{}
+	args := make([]string, len(parameters))
+	for idx, parameter := range parameters {
+		counts[parameter]++
+		args[idx] = fmt.Sprintf("%s%d", parameter, counts[parameter])
+		if language == "ts" {
+			args[idx] += ": " + snippetTypes[parameter]
+		}
+	}
+
+	quoted := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(expression)
+
+	return fmt.Sprintf(`habitable.addStep("%s", function (%s) {
+  return new Error("step not yet implemented");
+});
+`, quoted, strings.Join(args, ", "))
+}
@@ -0,0 +1,264 @@
+package scripting
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cucumber/messages-go/v16"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/plugins"
+)
+
+type StepParam struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+type StepDefinition struct {
+	Pattern string      `json:"pattern"`
+	Source  string      `json:"source"`
+	Line    int         `json:"line"`
+	Params  []StepParam `json:"params"`
+	Doc     string      `json:"doc,omitempty"`
+	Pending bool        `json:"pending,omitempty"`
+	Handler interface{} `json:"-"`
+}
+
+var (
+	collectingSteps bool
+	collectedSteps  []StepDefinition
+	compiledSteps   = map[string]*regexp.Regexp{}
+)
+
+func compileStep(pattern string) (*regexp.Regexp, error) {
+	if expr, ok := compiledSteps[pattern]; ok {
+		return expr, nil
+	}
+	expr, err := regexp.Compile(stepRegexp(pattern))
+	if err != nil {
+		return nil, err
+	}
+	compiledSteps[pattern] = expr
+	return expr, nil
+}
+
+func registerStep(definition StepDefinition) {
+	if collectingSteps {
+		common.AppLogger.Trace("collecting step %s from %s", definition.Pattern, definition.Source)
+		if definition.Params == nil {
+			definition.Params = stepParams(stepRegexp(definition.Pattern))
+		}
+		collectedSteps = append(collectedSteps, definition)
+		return
+	}
+
+	if scenarioContext == nil {
+		common.AppLogger.Trace("skipping adding step %s for %s, context not yet loaded", definition.Pattern, definition.Source)
+		return
+	}
+
+	common.AppLogger.Debug("adding step %s for %s", definition.Pattern, definition.Source)
+	scenarioContext.Step(stepRegexp(definition.Pattern), definition.Handler)
+}
+
+func CollectSteps() ([]StepDefinition, error) {
+	collectingSteps = true
+	collectedSteps = []StepDefinition{}
+	defer func() {
+		collectingSteps = false
+	}()
+	defer common.Variables.SetDefaultScope(common.Variables.SetDefaultScope(common.SuiteScope))
+
+	for name, script := range scripts {
+		common.AppLogger.Debug("running %s to collect defined steps", name)
+		if err := script.Run(); err != nil {
+			common.AppLogger.Error("failed to run script at path: %s", script.getPath())
+			return nil, err
+		}
+	}
+
+	for _, step := range plugins.CollectSteps() {
+		collectedSteps = append(collectedSteps, StepDefinition{
+			Pattern: step.Pattern,
+			Source:  "plugin:" + step.Plugin,
+			Params:  stepParams(step.Pattern),
+			Handler: step.Handler,
+		})
+	}
+
+	sort.SliceStable(collectedSteps, func(i, j int) bool {
+		if collectedSteps[i].Source != collectedSteps[j].Source {
+			return collectedSteps[i].Source < collectedSteps[j].Source
+		}
+		return collectedSteps[i].Line < collectedSteps[j].Line
+	})
+
+	return collectedSteps, nil
+}
+
+type stepArgumentKey struct{}
+
+// WithStepArgument carries the DocString or table of a step through to script
+// handlers, which take it as an optional argument after the matched groups
+func WithStepArgument(ctx context.Context, argument *messages.PickleStepArgument) context.Context {
+	return context.WithValue(ctx, stepArgumentKey{}, argument)
+}
+
+func stepArgumentValue(ctx context.Context) interface{} {
+	argument, _ := ctx.Value(stepArgumentKey{}).(*messages.PickleStepArgument)
+	switch {
+	case argument == nil:
+		return nil
+	case argument.DocString != nil:
+		return argument.DocString.Content
+	case argument.DataTable != nil:
+		rows := make([][]string, len(argument.DataTable.Rows))
+		for idx, row := range argument.DataTable.Rows {
+			rows[idx] = make([]string, len(row.Cells))
+			for cellIdx, cell := range row.Cells {
+				rows[idx][cellIdx] = cell.Value
+			}
+		}
+		return rows
+	}
+	return nil
+}
+
+func newStepHandler(pattern string, call func(args []string, argument interface{}) error) (interface{}, error) {
+	expr, err := compileStep(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	argTypes := make([]reflect.Type, expr.NumSubexp()+1)
+	argTypes[0] = contextType
+	for idx := 1; idx < len(argTypes); idx++ {
+		argTypes[idx] = reflect.TypeOf("")
+	}
+	handlerType := reflect.FuncOf(argTypes, []reflect.Type{errorType}, false)
+
+	return reflect.MakeFunc(handlerType, func(values []reflect.Value) []reflect.Value {
+		args := make([]string, len(values)-1)
+		for idx, value := range values[1:] {
+			args[idx] = value.String()
+		}
+
+		result := reflect.New(errorType).Elem()
+		if err := call(args, stepArgumentValue(values[0].Interface().(context.Context))); err != nil {
+			if redacted := common.Redact(err.Error()); redacted != err.Error() {
+				err = errors.New(redacted)
+			}
+			result.Set(reflect.ValueOf(err))
+		}
+		return []reflect.Value{result}
+	}).Interface(), nil
+}
+
+func stepParams(pattern string) []StepParam {
+	params := []StepParam{}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return params
+	}
+
+	type group struct {
+		start int
+		param int
+	}
+	groups := []group{}
+	inClass := false
+	for idx := 0; idx < len(pattern); idx++ {
+		switch char := pattern[idx]; {
+		case char == '\\':
+			idx++
+		case inClass:
+			if char == ']' {
+				inClass = false
+			}
+		case char == '[':
+			inClass = true
+		case char == '(':
+			current := group{start: idx + 1, param: -1}
+			rest := pattern[idx+1:]
+			switch {
+			case strings.HasPrefix(rest, "?P<"):
+				nameEnd := strings.Index(rest, ">")
+				current.start += nameEnd + 1
+				current.param = len(params)
+				params = append(params, StepParam{Name: rest[3:nameEnd]})
+			case strings.HasPrefix(rest, "?"):
+			default:
+				current.param = len(params)
+				params = append(params, StepParam{Name: fmt.Sprintf("arg%d", len(params)+1)})
+			}
+			groups = append(groups, current)
+		case char == ')' && len(groups) > 0:
+			current := groups[len(groups)-1]
+			groups = groups[:len(groups)-1]
+			if current.param >= 0 {
+				params[current.param].Pattern = pattern[current.start:idx]
+			}
+		}
+	}
+
+	return params
+}
+
+func docComment(path string, line int, prefixes ...string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() && len(lines) < line-1 {
+		lines = append(lines, scanner.Text())
+	}
+
+	doc := []string{}
+	for idx := len(lines) - 1; idx >= 0; idx-- {
+		text := strings.TrimSpace(lines[idx])
+		matched := false
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(text, prefix) {
+				doc = append([]string{strings.TrimSpace(strings.TrimPrefix(text, prefix))}, doc...)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			break
+		}
+	}
+
+	return strings.Join(doc, "\n")
+}
+
+func applyStepVariables(habitable *Habitable, vars map[string]interface{}) error {
+	for key, value := range vars {
+		common.AppLogger.Debug("step returned variable %s", key)
+		switch value.(type) {
+		case nil:
+			habitable.Variables.Set(key, "")
+		case string, bool, int, int64, float64:
+			habitable.Variables.Set(key, fmt.Sprint(value))
+		default:
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("step returned variable %s which cannot be stored: %s", key, err.Error())
+			}
+			habitable.Variables.Set(key, string(encoded))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package scripting
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStepParams(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected []StepParam
+	}{
+		{"^no params$", []StepParam{}},
+		{`^I have (\d+) cukes$`, []StepParam{{Name: "arg1", Pattern: `\d+`}}},
+		{`^(\w+) gives (\d+) to (\w+)$`, []StepParam{{Name: "arg1", Pattern: `\w+`}, {Name: "arg2", Pattern: `\d+`}, {Name: "arg3", Pattern: `\w+`}}},
+		{`^(?P<name>\w+) is (\d+)$`, []StepParam{{Name: "name", Pattern: `\w+`}, {Name: "arg2", Pattern: `\d+`}}},
+		{`^(?:a|an) (\w+)$`, []StepParam{{Name: "arg1", Pattern: `\w+`}}},
+		{`^((\d+) items)$`, []StepParam{{Name: "arg1", Pattern: `(\d+) items`}, {Name: "arg2", Pattern: `\d+`}}},
+		{`^literal \(not a group\) ([(]x[)])$`, []StepParam{{Name: "arg1", Pattern: `[(]x[)]`}}},
+		{`^unbalanced (\d+$`, []StepParam{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			if params := stepParams(test.pattern); !reflect.DeepEqual(params, test.expected) {
+				t.Errorf("stepParams(%q) = %+v, want %+v", test.pattern, params, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package scripting
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/marmotherder/habitable/common"
+)
+
+var templateFunctions = map[string]interface{}{}
+
+func TemplateFunctions() map[string]interface{} {
+	functions := map[string]interface{}{}
+	for name, function := range templateFunctions {
+		functions[name] = function
+	}
+	return functions
+}
+
+func registerTemplateFunction(name string, function interface{}) {
+	if reflect.ValueOf(function).Kind() != reflect.Func {
+		common.AppLogger.Error("template function %s is not a function", name)
+		return
+	}
+	common.AppLogger.Debug("registering template function %s", name)
+	templateFunctions[name] = function
+}
+
+func templateFunctionError(name string, err error) error {
+	return fmt.Errorf("template function %s failed: %s", name, err.Error())
+}
@@ -0,0 +1,137 @@
+package scripting
+
+import (
+	"regexp/syntax"
+
+	"github.com/marmotherder/habitable/common"
+)
+
+const wildcardToken = -2
+
+// MatchingStepsWithWildcards matches step text that is only partly known, the
+// parts are the known pieces of the text and any value a step parameter
+// accepts may appear between them
+func MatchingStepsWithWildcards(definitions []StepDefinition, parts []string) []StepDefinition {
+	if len(parts) < 2 {
+		return MatchingSteps(definitions, parts[0])
+	}
+
+	tokens := []rune{}
+	for idx, part := range parts {
+		if idx > 0 {
+			tokens = append(tokens, wildcardToken)
+		}
+		tokens = append(tokens, []rune(part)...)
+	}
+
+	matches := []StepDefinition{}
+	for _, definition := range definitions {
+		expr, err := compileStep(definition.Pattern)
+		if err != nil {
+			common.AppLogger.Warn("could not compile step pattern %s: %s", definition.Pattern, err.Error())
+			continue
+		}
+		re, err := syntax.Parse(`(?s)(.*)(?:`+expr.String()+`)(.*)`, syntax.Perl)
+		if err != nil {
+			continue
+		}
+		prog, err := syntax.Compile(re.Simplify())
+		if err != nil {
+			continue
+		}
+		if matchWildcardTokens(prog, tokens) {
+			matches = append(matches, definition)
+		}
+	}
+
+	return matches
+}
+
+// matchWildcardTokens walks the program and the tokens together, a wildcard
+// token can be empty or consume any runes inside a capture group, so a match
+// means some parameter values exist that make the whole text match. Text
+// around an unanchored pattern is captured too, so wildcards can fill it
+func matchWildcardTokens(prog *syntax.Prog, tokens []rune) bool {
+	type state struct {
+		pc    uint32
+		pos   int
+		depth int
+	}
+
+	visited := map[state]bool{}
+	pending := []state{{uint32(prog.Start), 0, 0}}
+	push := func(s state) {
+		if !visited[s] {
+			visited[s] = true
+			pending = append(pending, s)
+		}
+	}
+
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if current.pos < len(tokens) && tokens[current.pos] == wildcardToken {
+			push(state{current.pc, current.pos + 1, current.depth})
+		}
+
+		inst := prog.Inst[current.pc]
+		switch inst.Op {
+		case syntax.InstMatch:
+			if current.pos == len(tokens) {
+				return true
+			}
+		case syntax.InstAlt, syntax.InstAltMatch:
+			push(state{inst.Out, current.pos, current.depth})
+			push(state{inst.Arg, current.pos, current.depth})
+		case syntax.InstCapture:
+			if inst.Arg%2 == 0 {
+				push(state{inst.Out, current.pos, current.depth + 1})
+			} else {
+				push(state{inst.Out, current.pos, current.depth - 1})
+			}
+		case syntax.InstNop:
+			push(state{inst.Out, current.pos, current.depth})
+		case syntax.InstEmptyWidth:
+			if wildcardEmptyWidth(tokens, current.pos)&syntax.EmptyOp(inst.Arg) == syntax.EmptyOp(inst.Arg) {
+				push(state{inst.Out, current.pos, current.depth})
+			}
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			if current.pos == len(tokens) {
+				continue
+			}
+			if token := tokens[current.pos]; token == wildcardToken {
+				if current.depth > 0 {
+					push(state{inst.Out, current.pos, current.depth})
+				}
+			} else if inst.MatchRune(token) {
+				push(state{inst.Out, current.pos + 1, current.depth})
+			}
+		}
+	}
+
+	return false
+}
+
+func wildcardEmptyWidth(tokens []rune, pos int) syntax.EmptyOp {
+	before, after := rune(-1), rune(-1)
+	if pos > 0 {
+		before = tokens[pos-1]
+	}
+	if pos < len(tokens) {
+		after = tokens[pos]
+	}
+
+	if before != wildcardToken && after != wildcardToken {
+		return syntax.EmptyOpContext(before, after)
+	}
+
+	ops := syntax.EmptyBeginLine | syntax.EmptyEndLine | syntax.EmptyWordBoundary | syntax.EmptyNoWordBoundary
+	if pos == 0 {
+		ops |= syntax.EmptyBeginText
+	}
+	if pos == len(tokens) {
+		ops |= syntax.EmptyEndText
+	}
+	return ops
+}
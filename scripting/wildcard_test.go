@@ -0,0 +1,49 @@
+package scripting
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchingStepsWithWildcards(t *testing.T) {
+	definitions := []StepDefinition{
+		{Pattern: "the order {int} is shipped"},
+		{Pattern: "the order {string} is paid"},
+		{Pattern: `^I wait (\d+) seconds$`},
+		{Pattern: `^a \bword\b step$`},
+		{Pattern: "plain text"},
+	}
+
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"the order \x00 is shipped", []string{"the order {int} is shipped"}},
+		{"the order 12\x00 is shipped", []string{"the order {int} is shipped"}},
+		{"the order \"\x00\" is paid", []string{"the order {string} is paid"}},
+		{"the order \x00 is paid", nil},
+		{"the order x\x00 is shipped", nil},
+		{"I wait \x00 seconds", []string{`^I wait (\d+) seconds$`}},
+		{"I wait \x00 minutes", nil},
+		{"a \x00 step", nil},
+		{"a word\x00 step", []string{`^a \bword\b step$`}},
+		{"\x00", nil},
+		{"\x00 plain text", []string{"plain text"}},
+		{"plain text", []string{"plain text"}},
+		{"plain \x00", nil},
+		{"other \x00", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.ReplaceAll(test.text, "\x00", "*"), func(t *testing.T) {
+			matches := MatchingStepsWithWildcards(definitions, strings.Split(test.text, "\x00"))
+			patterns := []string{}
+			for _, match := range matches {
+				patterns = append(patterns, match.Pattern)
+			}
+			if strings.Join(patterns, "|") != strings.Join(test.expected, "|") {
+				t.Errorf("matched %q, want %q", patterns, test.expected)
+			}
+		})
+	}
+}
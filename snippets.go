@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/scripting"
+)
+
+var undefinedSteps []string
+
+func addUndefinedStep(text string) {
+	expression, _ := scripting.SnippetExpression(text)
+	for _, existing := range undefinedSteps {
+		if existingExpression, _ := scripting.SnippetExpression(existing); existingExpression == expression {
+			return
+		}
+	}
+	undefinedSteps = append(undefinedSteps, text)
+}
+
+func writeSnippets(out io.Writer) error {
+	if opts.Snippets == "none" || len(undefinedSteps) == 0 {
+		return nil
+	}
+
+	snippets := ""
+	for _, text := range undefinedSteps {
+		snippets += "\n" + scripting.Snippet(text, opts.Snippets)
+	}
+
+	fmt.Fprintf(out, "\nYou can implement step definitions for undefined steps with these %s snippets:\n%s", opts.Snippets, snippets)
+
+	if opts.SnippetsFile == "" || len(opts.ScriptDirs) == 0 {
+		return nil
+	}
+
+	snippetsFile := filepath.Join(opts.ScriptDirs[0], opts.SnippetsFile)
+	common.AppLogger.Info("appending snippets for undefined steps to %s", snippetsFile)
+	file, err := os.OpenFile(snippetsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(snippets)
+	return err
+}
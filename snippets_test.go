@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cucumber/godog"
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/logger"
+)
+
+func TestRunSuiteUndefinedStepReport(t *testing.T) {
+	common.AppLogger = logger.DefaultLogger{}
+	common.Variables = common.NewHabitableVariables(nil)
+	opts.Snippets = "js"
+
+	feature := filepath.Join(t.TempDir(), "undefined.feature")
+	if err := os.WriteFile(feature, []byte("Feature: undefined\n  Scenario: undefined\n    Given I have 3 cukes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr := captureOutput(t, func() {
+		runSuite(&godog.Options{Paths: []string{feature}, Format: "junit"})
+	})
+
+	report := struct {
+		XMLName xml.Name `xml:"testsuites"`
+	}{}
+	decoder := xml.NewDecoder(strings.NewReader(stdout))
+	if err := decoder.Decode(&report); err != nil {
+		t.Errorf("junit report is not valid xml: %s\n%s", err.Error(), stdout)
+	}
+	if offset := decoder.InputOffset(); strings.TrimSpace(stdout[offset:]) != "" {
+		t.Errorf("junit report is followed by other output: %s", stdout[offset:])
+	}
+	if strings.Contains(stdout, "habitable.addStep") {
+		t.Errorf("snippets were written to stdout:\n%s", stdout)
+	}
+	if !strings.Contains(stderr, "habitable.addStep") {
+		t.Errorf("snippets were not written to stderr:\n%s", stderr)
+	}
+}
+
+func captureOutput(t *testing.T, run func()) (string, string) {
+	t.Helper()
+	stdout, stderr := os.Stdout, os.Stderr
+	defer func() {
+		os.Stdout, os.Stderr = stdout, stderr
+	}()
+
+	outputs := make([]string, 2)
+	files := []**os.File{&os.Stdout, &os.Stderr}
+	done := make(chan struct{})
+	for idx, file := range files {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		*file = writer
+		go func(idx int, reader *os.File) {
+			output, _ := io.ReadAll(reader)
+			outputs[idx] = string(output)
+			done <- struct{}{}
+		}(idx, reader)
+	}
+
+	run()
+	for _, file := range files {
+		(*file).Close()
+	}
+	for range files {
+		<-done
+	}
+	return outputs[0], outputs[1]
+}
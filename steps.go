@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/features"
+	"github.com/marmotherder/habitable/scripting"
+)
+
+type stepsCommand struct {
+	Format string `short:"o" long:"output" description:"Output format for the step listing" choice:"text" choice:"json" choice:"markdown" default:"text"`
+}
+
+func listSteps(out io.Writer, format string) error {
+	steps, err := scripting.CollectSteps()
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(steps)
+	case "markdown":
+		fmt.Fprintln(out, "# Step definitions")
+		for _, step := range steps {
+			fmt.Fprintf(out, "\n## `%s`\n\n", step.Pattern)
+			fmt.Fprintf(out, "Source: `%s`\n", stepLocation(step))
+			if len(step.Params) > 0 {
+				fmt.Fprintln(out, "\n| Parameter | Pattern |")
+				fmt.Fprintln(out, "| --- | --- |")
+				for _, param := range step.Params {
+					fmt.Fprintf(out, "| %s | `%s` |\n", param.Name, strings.ReplaceAll(param.Pattern, "|", "\\|"))
+				}
+			}
+			if step.Doc != "" {
+				fmt.Fprintf(out, "\n%s\n", step.Doc)
+			}
+		}
+	default:
+		for idx, step := range steps {
+			if idx > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprintln(out, step.Pattern)
+			fmt.Fprintf(out, "  source: %s\n", stepLocation(step))
+			for _, param := range step.Params {
+				fmt.Fprintf(out, "  param:  %s %s\n", param.Name, param.Pattern)
+			}
+			for _, line := range strings.Split(step.Doc, "\n") {
+				if line != "" {
+					fmt.Fprintf(out, "  %s\n", line)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func stepLocation(step scripting.StepDefinition) string {
+	if step.Line > 0 {
+		return fmt.Sprintf("%s:%d", step.Source, step.Line)
+	}
+	return step.Source
+}
+
+func checkStepConflicts(paths []string, strict bool) error {
+	definitions, err := scripting.CollectSteps()
+	if err != nil {
+		return err
+	}
+	steps, err := features.LoadSteps(paths...)
+	if err != nil {
+		if strict {
+			return err
+		}
+		common.AppLogger.Warn("failed to load features to check for ambiguous steps: %s", err.Error())
+	}
+
+	conflicts := scripting.FindStepConflicts(definitions, steps)
+	for _, conflict := range conflicts {
+		sb := strings.Builder{}
+		if conflict.Duplicate() {
+			fmt.Fprintf(&sb, "step pattern %s is registered more than once:", conflict.Definitions[0].Pattern)
+			for _, definition := range conflict.Definitions {
+				fmt.Fprintf(&sb, "\n  %s", stepLocation(definition))
+			}
+		} else {
+			fmt.Fprintf(&sb, "step \"%s\" at %s is ambiguous, it matches:", conflict.Step.Text, conflict.Step.Location())
+			for _, definition := range conflict.Definitions {
+				fmt.Fprintf(&sb, "\n  %s (%s)", definition.Pattern, stepLocation(definition))
+			}
+		}
+		if strict {
+			common.AppLogger.Error(sb.String())
+		} else {
+			common.AppLogger.Warn(sb.String())
+		}
+	}
+
+	if strict && len(conflicts) > 0 {
+		return fmt.Errorf("found %d conflicting step definitions", len(conflicts))
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cucumber/godog"
+	"github.com/cucumber/messages-go/v16"
+	"github.com/hoisie/mustache"
+
+	"github.com/marmotherder/habitable/common"
+)
+
+var mustacheVariablePattern = regexp.MustCompile(`\{\{\s*[{&#^]?\s*([^\s{}!/>=][^\s{}]*)\s*\}?\}\}`)
+
+func unresolvedVariables(text string, variables map[string]string) []string {
+	names := []string{}
+	if opts.Template == goTemplates {
+		names = goTemplateFields(text)
+	} else {
+		for _, match := range mustacheVariablePattern.FindAllStringSubmatch(text, -1) {
+			if match[1] != "." {
+				names = append(names, match[1])
+			}
+		}
+	}
+
+	unresolved := []string{}
+	for _, name := range names {
+		if _, ok := variables[name]; !ok {
+			unresolved = append(unresolved, name)
+		}
+	}
+	return unresolved
+}
+
+func renderText(text string, strict bool) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	return renderTemplate(text, common.Variables.Map(), strict)
+}
+
+func renderTemplate(text string, variables map[string]string, strict bool) (string, error) {
+	if strict {
+		if unresolved := unresolvedVariables(text, variables); len(unresolved) > 0 {
+			return "", fmt.Errorf("unresolved variables: %s", strings.Join(unresolved, ", "))
+		}
+	}
+
+	if opts.Template == goTemplates {
+		return renderGoTemplate(text, variables)
+	}
+
+	template, err := mustache.ParseString(text)
+	if err != nil {
+		return "", err
+	}
+	return template.Render(variables), nil
+}
+
+func renderStep(st *godog.Step, strict bool) error {
+	return transformStep(st, func(text string) (string, error) {
+		return renderText(text, strict)
+	})
+}
+
+func redactStep(st *godog.Step) {
+	transformStep(st, func(text string) (string, error) {
+		return common.Redact(text), nil
+	})
+}
+
+func transformStep(st *godog.Step, transform func(string) (string, error)) error {
+	texts := []*string{&st.Text}
+	texts = append(texts, stepArgumentValues(st.Argument)...)
+
+	for _, text := range texts {
+		transformed, err := transform(*text)
+		if err != nil {
+			return err
+		}
+		*text = transformed
+	}
+	return nil
+}
+
+func stepArgumentValues(argument *messages.PickleStepArgument) []*string {
+	values := []*string{}
+	if argument == nil {
+		return values
+	}
+	if argument.DocString != nil {
+		values = append(values, &argument.DocString.Content)
+	}
+	if argument.DataTable != nil {
+		for _, row := range argument.DataTable.Rows {
+			for _, cell := range row.Cells {
+				values = append(values, &cell.Value)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/marmotherder/habitable/scripting"
+)
+
+const (
+	mustacheTemplates = "mustache"
+	goTemplates       = "go"
+)
+
+var timeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"Stamp":       time.Stamp,
+	"StampMilli":  time.StampMilli,
+	"DateTime":    "2006-01-02 15:04:05",
+	"DateOnly":    "2006-01-02",
+	"TimeOnly":    "15:04:05",
+}
+
+var random = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+func templateFuncs() template.FuncMap {
+	funcs := template.FuncMap{
+		"uuid": func() (string, error) {
+			id, err := uuid.NewV4()
+			if err != nil {
+				return "", err
+			}
+			return id.String(), nil
+		},
+		"now": func(layout ...string) string {
+			format := time.RFC3339
+			if len(layout) > 0 {
+				format = layout[0]
+				if named, ok := timeLayouts[format]; ok {
+					format = named
+				}
+			}
+			return time.Now().Format(format)
+		},
+		"unix": func() int64 {
+			return time.Now().Unix()
+		},
+		"randInt": func(min, max interface{}) (int, error) {
+			lower, upper, err := templateInts(min, max)
+			if err != nil {
+				return 0, err
+			}
+			if upper <= lower {
+				return 0, fmt.Errorf("randInt max %d must be greater than min %d", upper, lower)
+			}
+			return lower + random.Intn(upper-lower), nil
+		},
+		"default": func(def interface{}, value ...interface{}) interface{} {
+			if len(value) == 0 || isEmptyTemplateValue(value[0]) {
+				return def
+			}
+			return value[0]
+		},
+		"b64enc": func(value string) string {
+			return base64.StdEncoding.EncodeToString([]byte(value))
+		},
+		"b64dec": func(value string) (string, error) {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			return string(decoded), err
+		},
+		"jsonEscape": func(value string) (string, error) {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return "", err
+			}
+			return string(encoded[1 : len(encoded)-1]), nil
+		},
+		"env":   os.Getenv,
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"trim":  strings.TrimSpace,
+		"add":   templateArithmetic(func(a, b int) (int, error) { return a + b, nil }),
+		"sub":   templateArithmetic(func(a, b int) (int, error) { return a - b, nil }),
+		"mul":   templateArithmetic(func(a, b int) (int, error) { return a * b, nil }),
+		"div": templateArithmetic(func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return a / b, nil
+		}),
+		"mod": templateArithmetic(func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return a % b, nil
+		}),
+	}
+
+	for name, function := range scripting.TemplateFunctions() {
+		funcs[name] = function
+	}
+
+	return funcs
+}
+
+func templateArithmetic(operation func(a, b int) (int, error)) func(a, b interface{}) (int, error) {
+	return func(a, b interface{}) (int, error) {
+		x, y, err := templateInts(a, b)
+		if err != nil {
+			return 0, err
+		}
+		return operation(x, y)
+	}
+}
+
+func templateInts(a, b interface{}) (int, int, error) {
+	x, err := templateInt(a)
+	if err != nil {
+		return 0, 0, err
+	}
+	y, err := templateInt(b)
+	if err != nil {
+		return 0, 0, err
+	}
+	return x, y, nil
+}
+
+func templateInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	case string:
+		return strconv.Atoi(strings.TrimSpace(v))
+	}
+	return 0, fmt.Errorf("cannot use %v as a number", value)
+}
+
+func isEmptyTemplateValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
+
+// renderGoTemplate leaves strictness to unresolvedVariables, as missing keys
+// must render empty for default to fall back
+func renderGoTemplate(text string, variables map[string]string) (string, error) {
+	tmpl, err := template.New("step").Funcs(templateFuncs()).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	sb := strings.Builder{}
+	if err := tmpl.Execute(&sb, variables); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func goTemplateFields(text string) []string {
+	funcs := template.FuncMap{}
+	for name := range templateFuncs() {
+		funcs[name] = func() string { return "" }
+	}
+
+	tmpl, err := template.New("step").Funcs(funcs).Parse(text)
+	if err != nil || tmpl.Tree == nil {
+		return []string{}
+	}
+
+	fields := []string{}
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "default" {
+					return
+				}
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			fields = append(fields, n.Ident[0])
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		}
+	}
+	walk(tmpl.Tree.Root)
+
+	return fields
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRenderGoTemplateStrict(t *testing.T) {
+	defer func(template string) {
+		opts.Template = template
+	}(opts.Template)
+	opts.Template = goTemplates
+	variables := map[string]string{"name": "bob"}
+
+	tests := []struct {
+		text     string
+		expected string
+		invalid  bool
+	}{
+		{"hello {{ .name }}", "hello bob", false},
+		{`hello {{ .missing | default "alice" }}`, "hello alice", false},
+		{`hello {{ default "alice" .missing }}`, "hello alice", false},
+		{`hello {{ .name | default "alice" }}`, "hello bob", false},
+		{"hello {{ .missing }}", "", true},
+		{"hello {{ upper .missing }}", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			rendered, err := renderTemplate(test.text, variables, true)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("renderTemplate(%q) = %q, want an unresolved variable error", test.text, rendered)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderTemplate(%q) returned error: %s", test.text, err.Error())
+			}
+			if rendered != test.expected {
+				t.Errorf("renderTemplate(%q) = %q, want %q", test.text, rendered, test.expected)
+			}
+		})
+	}
+}
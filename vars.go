@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/marmotherder/habitable/common"
+)
+
+func loadVariables(config *habitableConfig) (map[string]string, error) {
+	variables := map[string]string{}
+	for _, env := range os.Environ() {
+		kv := strings.SplitN(env, "=", 2)
+		variables[kv[0]] = kv[1]
+	}
+
+	files := []string{}
+	if opts.Profile != "" {
+		profile, err := config.profile(opts.Profile)
+		if err != nil {
+			return nil, err
+		}
+		common.AppLogger.Debug("applying variables from profile %s", opts.Profile)
+		if err := mergeVariables(variables, profile.Vars); err != nil {
+			return nil, fmt.Errorf("profile %s: %s", opts.Profile, err.Error())
+		}
+		files = append(files, profile.Files...)
+	}
+	files = append(files, opts.Vars...)
+
+	for _, file := range files {
+		common.AppLogger.Debug("loading variables from %s", file)
+		values, err := loadVariablesFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			variables[key] = value
+		}
+	}
+
+	return variables, nil
+}
+
+func loadSecrets(config *habitableConfig) error {
+	patterns := append([]string{}, config.Secrets.Patterns...)
+	for _, prefix := range config.Secrets.Prefixes {
+		patterns = append(patterns, prefix+"*")
+	}
+	patterns = append(patterns, opts.SecretPattern...)
+	for _, pattern := range patterns {
+		if err := common.Variables.AddSecretPattern(pattern); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range append(append([]string{}, config.Secrets.Files...), opts.Secrets...) {
+		common.AppLogger.Debug("loading secrets from %s", file)
+		values, err := loadVariablesFile(file)
+		if err != nil {
+			return err
+		}
+		for key, value := range values {
+			common.Variables.MarkSecret(key)
+			common.Variables.SetScoped(common.EnvironmentScope, key, value)
+		}
+	}
+
+	return nil
+}
+
+func loadVariablesFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read variables file %s: %s", path, err.Error())
+	}
+
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".env", "":
+		var envValues map[string]string
+		envValues, err = common.ParseEnv(strings.NewReader(string(data)))
+		for key, value := range envValues {
+			values[key] = value
+		}
+	default:
+		return nil, fmt.Errorf("unsupported variables file %s, expected .env, .json or .yaml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse variables file %s: %s", path, err.Error())
+	}
+
+	variables := map[string]string{}
+	if err := mergeVariables(variables, values); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return variables, nil
+}
+
+func mergeVariables(variables map[string]string, values map[string]interface{}) error {
+	for key, value := range values {
+		switch v := value.(type) {
+		case nil:
+			variables[key] = ""
+		case string:
+			variables[key] = v
+		case map[string]interface{}, []interface{}:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("could not encode variable %s: %s", key, err.Error())
+			}
+			variables[key] = string(encoded)
+		default:
+			variables[key] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cucumber/godog"
+
+	"github.com/marmotherder/habitable/common"
+	"github.com/marmotherder/habitable/copy"
+	"github.com/marmotherder/habitable/features"
+	"github.com/marmotherder/habitable/plugins"
+	"github.com/marmotherder/habitable/scripting"
+)
+
+const (
+	watchFeature = "feature"
+	watchScript  = "script"
+	watchPlugin  = "plugin"
+)
+
+type watchedFile struct {
+	kind    string
+	modTime time.Time
+	size    int64
+}
+
+func watchedFiles() map[string]watchedFile {
+	files := map[string]watchedFile{}
+	walk := func(root, kind string, include func(string) bool) {
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() {
+				if info.Name() == "node_modules" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if include(path) {
+				files[path] = watchedFile{
+					kind:    kind,
+					modTime: info.ModTime(),
+					size:    info.Size(),
+				}
+			}
+			return nil
+		})
+	}
+
+	for _, path := range features.Paths(opts.Tests) {
+		path = strings.SplitN(path, ":", 2)[0]
+		walk(path, watchFeature, func(path string) bool {
+			return strings.HasSuffix(path, ".feature")
+		})
+	}
+	for _, scriptDir := range opts.ScriptDirs {
+		walk(scriptDir, watchScript, func(string) bool {
+			return true
+		})
+	}
+	for _, data := range plugins.LoadPlugins {
+		for _, location := range plugins.LocalLocations(data) {
+			if copy.Exists(location) {
+				walk(location, watchPlugin, func(string) bool {
+					return true
+				})
+			}
+		}
+	}
+
+	return files
+}
+
+func watch(godogOpts *godog.Options) {
+	common.AppLogger.Info("watching for changes every %s", opts.WatchInterval)
+	previous := watchedFiles()
+	for {
+		time.Sleep(opts.WatchInterval)
+		current := watchedFiles()
+
+		changed := map[string][]string{}
+		for path, file := range current {
+			if old, ok := previous[path]; !ok || !old.modTime.Equal(file.modTime) || old.size != file.size {
+				changed[file.kind] = append(changed[file.kind], path)
+			}
+		}
+		for path, file := range previous {
+			if _, ok := current[path]; !ok {
+				changed[file.kind] = append(changed[file.kind], path)
+			}
+		}
+		previous = current
+
+		if len(changed) == 0 {
+			continue
+		}
+
+		if len(changed[watchPlugin]) > 0 {
+			common.AppLogger.Info("plugin files %s changed, restarting habitable", changed[watchPlugin])
+			executable, err := os.Executable()
+			if err != nil {
+				common.AppLogger.Fatal(common.SetupError, err.Error())
+			}
+			plugins.ClosePlugins()
+			if err := syscall.Exec(executable, os.Args, os.Environ()); err != nil {
+				common.AppLogger.Fatal(common.SetupError, err.Error())
+			}
+		}
+
+		runOpts := *godogOpts
+		if len(changed[watchScript]) > 0 {
+			common.AppLogger.Info("script files %s changed, reloading scripts", changed[watchScript])
+			if err := scripting.LoadScripts(opts.ScriptDirs...); err != nil {
+				common.AppLogger.Error(err.Error())
+				continue
+			}
+			if err := checkStepConflicts(opts.Tests, opts.StrictSteps); err != nil {
+				common.AppLogger.Error(err.Error())
+				continue
+			}
+		} else {
+			runOpts.Paths = []string{}
+			for _, path := range changed[watchFeature] {
+				if _, ok := current[path]; ok {
+					runOpts.Paths = append(runOpts.Paths, path)
+				}
+			}
+			if len(runOpts.Paths) == 0 {
+				continue
+			}
+			common.AppLogger.Info("feature files %s changed, re-running them", runOpts.Paths)
+		}
+
+		status := runSuite(&runOpts)
+		common.AppLogger.Info(status)
+	}
+}